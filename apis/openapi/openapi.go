@@ -0,0 +1,332 @@
+// Package openapi embeds the OpenAPI document of the todo REST API and
+// checks values and Go types against it.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+var (
+	ErrNotDocumented = errors.New("not documented")
+	ErrInvalid       = errors.New("does not match schema")
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []string           `json:"enum"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+	Headers map[string]any       `json:"headers"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Document struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas   map[string]*Schema  `json:"schemas"`
+		Responses map[string]Response `json:"responses"`
+	} `json:"components"`
+}
+
+func Load() (*Document, error) {
+	d := &Document{}
+	if err := json.Unmarshal(Spec, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Operations returns every documented "METHOD path" pair.
+func (d *Document) Operations() []string {
+	ops := []string{}
+	for path, item := range d.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	slices.Sort(ops)
+	return ops
+}
+
+// Operation finds the operation serving a concrete request path, matching
+// templated segments such as {id}. Literal paths win over templated ones.
+// It returns the templated path as well.
+func (d *Document) Operation(method, path string) (string, *Operation, error) {
+	tmpl := ""
+	if _, ok := d.Paths[path][strings.ToLower(method)]; ok {
+		tmpl = path
+	} else {
+		for p, item := range d.Paths {
+			if _, ok := item[strings.ToLower(method)]; ok && matchPath(p, path) {
+				tmpl = p
+				break
+			}
+		}
+	}
+	if tmpl == "" {
+		return "", nil, fmt.Errorf("%w: %s %s", ErrNotDocumented, method, path)
+	}
+
+	op := &Operation{}
+	if err := json.Unmarshal(d.Paths[tmpl][strings.ToLower(method)], op); err != nil {
+		return "", nil, err
+	}
+	return tmpl, op, nil
+}
+
+func matchPath(tmpl, path string) bool {
+	ts := strings.Split(tmpl, "/")
+	ps := strings.Split(path, "/")
+	if len(ts) != len(ps) {
+		return false
+	}
+	for i := range ts {
+		if strings.HasPrefix(ts[i], "{") {
+			if ps[i] == "" {
+				return false
+			}
+			continue
+		}
+		if ts[i] != ps[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Response returns the documented response for status, with references resolved.
+func (d *Document) Response(op *Operation, status int) (*Response, error) {
+	r, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return nil, fmt.Errorf("%w: status %d for %s", ErrNotDocumented, status, op.OperationID)
+	}
+	if ref := r.Ref; ref != "" {
+		r, ok = d.Components.Responses[strings.TrimPrefix(ref, "#/components/responses/")]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotDocumented, ref)
+		}
+	}
+	return &r, nil
+}
+
+// Schema returns the body schema of a response for the given Content-Type.
+func (r *Response) Schema(contentType string) (*Schema, error) {
+	if len(r.Content) == 0 {
+		return nil, nil
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: content type %q", ErrNotDocumented, contentType)
+	}
+	m, ok := r.Content[mt]
+	if !ok {
+		return nil, fmt.Errorf("%w: content type %q", ErrNotDocumented, mt)
+	}
+	return m.Schema, nil
+}
+
+// Component returns a named schema from components/schemas.
+func (d *Document) Component(name string) (*Schema, error) {
+	s, ok := d.Components.Schemas[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: schema %s", ErrNotDocumented, name)
+	}
+	return s, nil
+}
+
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+	return d.Component(strings.TrimPrefix(s.Ref, "#/components/schemas/"))
+}
+
+// Validate checks a decoded JSON value against a schema.
+func (d *Document) Validate(s *Schema, v any) error {
+	return d.validate(s, v, "$")
+}
+
+func (d *Document) validate(s *Schema, v any, at string) error {
+	s, err := d.resolve(s)
+	if err != nil {
+		return err
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: %s is not an object", ErrInvalid, at)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%w: %s.%s is required", ErrInvalid, at, name)
+			}
+		}
+		for name, value := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%w: %s.%s is not allowed", ErrInvalid, at, name)
+				}
+				continue
+			}
+			if err := d.validate(prop, value, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%w: %s is not an array", ErrInvalid, at)
+		}
+		for i, value := range arr {
+			if err := d.validate(s.Items, value, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%w: %s is not a string", ErrInvalid, at)
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%w: %s is not one of %v", ErrInvalid, at, s.Enum)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%w: %s is not a date-time", ErrInvalid, at)
+			}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%w: %s is not an integer", ErrInvalid, at)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%w: %s is not a number", ErrInvalid, at)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%w: %s is not a boolean", ErrInvalid, at)
+		}
+	}
+	return nil
+}
+
+// CheckType verifies that the JSON encoding of Go type t matches a named
+// schema: every field maps to a documented property of a compatible type
+// and every required property has a field.
+func (d *Document) CheckType(name string, t reflect.Type) error {
+	s, err := d.Component(name)
+	if err != nil {
+		return err
+	}
+	return d.checkType(s, t, name)
+}
+
+func (d *Document) checkType(s *Schema, t reflect.Type, at string) error {
+	s, err := d.resolve(s)
+	if err != nil {
+		return err
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		if s.Type != "string" || s.Format != "date-time" {
+			return fmt.Errorf("%w: %s is a time, schema has %s", ErrInvalid, at, s.Type)
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if s.Type != "object" {
+			return fmt.Errorf("%w: %s is an object, schema has %s", ErrInvalid, at, s.Type)
+		}
+		fields := map[string]bool{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			prop, ok := s.Properties[name]
+			if !ok {
+				return fmt.Errorf("%w: %s.%s is not documented", ErrInvalid, at, name)
+			}
+			if err := d.checkType(prop, f.Type, at+"."+name); err != nil {
+				return err
+			}
+			fields[name] = true
+		}
+		for _, name := range s.Required {
+			if !fields[name] {
+				return fmt.Errorf("%w: %s.%s is required but has no field", ErrInvalid, at, name)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if s.Type != "array" {
+			return fmt.Errorf("%w: %s is an array, schema has %s", ErrInvalid, at, s.Type)
+		}
+		return d.checkType(s.Items, t.Elem(), at+"[]")
+	case reflect.String:
+		if s.Type != "string" {
+			return fmt.Errorf("%w: %s is a string, schema has %s", ErrInvalid, at, s.Type)
+		}
+	case reflect.Bool:
+		if s.Type != "boolean" {
+			return fmt.Errorf("%w: %s is a boolean, schema has %s", ErrInvalid, at, s.Type)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s.Type != "integer" {
+			return fmt.Errorf("%w: %s is an integer, schema has %s", ErrInvalid, at, s.Type)
+		}
+	case reflect.Float32, reflect.Float64:
+		if s.Type != "number" && s.Type != "integer" {
+			return fmt.Errorf("%w: %s is a number, schema has %s", ErrInvalid, at, s.Type)
+		}
+	default:
+		return fmt.Errorf("%w: %s has unsupported kind %s", ErrInvalid, at, t.Kind())
+	}
+	return nil
+}
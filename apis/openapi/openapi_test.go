@@ -0,0 +1,90 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"go-cmd-book/apis/openapi"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperation(t *testing.T) {
+	d, err := openapi.Load()
+	assert.NoError(t, err)
+
+	path, op, err := d.Operation(http.MethodPatch, "/todo/1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/todo/{id}", path)
+	assert.Equal(t, "updateItem", op.OperationID)
+
+	_, err = d.Response(op, http.StatusPreconditionFailed)
+	assert.NoError(t, err)
+	_, err = d.Response(op, http.StatusTeapot)
+	assert.ErrorIs(t, err, openapi.ErrNotDocumented)
+
+	_, _, err = d.Operation(http.MethodPut, "/todo/1")
+	assert.ErrorIs(t, err, openapi.ErrNotDocumented)
+	path, _, err = d.Operation(http.MethodGet, "/todo/events")
+	assert.NoError(t, err)
+	assert.Equal(t, "/todo/events", path)
+
+	_, _, err = d.Operation(http.MethodGet, "/todo/1/2")
+	assert.ErrorIs(t, err, openapi.ErrNotDocumented)
+}
+
+func TestValidate(t *testing.T) {
+	d, err := openapi.Load()
+	assert.NoError(t, err)
+	s, err := d.Component("TodoResponse")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		body string
+		err  error
+	}{
+		{"Valid", `{"date": 1, "total_results": 1, "results": [{"task": "a", "done": false, "created_at": "2024-06-03T16:24:49Z", "completed_at": "0001-01-01T00:00:00Z"}]}`, nil},
+		{"MissingField", `{"date": 1, "results": []}`, openapi.ErrInvalid},
+		{"ExtraField", `{"date": 1, "total_results": 0, "results": [], "next": 1}`, openapi.ErrInvalid},
+		{"WrongType", `{"date": "now", "total_results": 0, "results": []}`, openapi.ErrInvalid},
+		{"BadDate", `{"date": 1, "total_results": 1, "results": [{"task": "a", "done": false, "created_at": "yesterday", "completed_at": "0001-01-01T00:00:00Z"}]}`, openapi.ErrInvalid},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var v any
+			assert.NoError(t, json.Unmarshal([]byte(tc.body), &v))
+			assert.ErrorIs(t, d.Validate(s, v), tc.err)
+		})
+	}
+}
+
+func TestCheckType(t *testing.T) {
+	d, err := openapi.Load()
+	assert.NoError(t, err)
+
+	type item struct {
+		Task        string    `json:"task"`
+		Done        bool      `json:"done"`
+		CreatedAt   time.Time `json:"created_at"`
+		CompletedAt time.Time `json:"completed_at"`
+	}
+	assert.NoError(t, d.CheckType("Item", reflect.TypeOf(item{})))
+
+	type renamed struct {
+		Task string `json:"title"`
+	}
+	assert.ErrorIs(t, d.CheckType("NewItem", reflect.TypeOf(renamed{})), openapi.ErrInvalid)
+
+	type missing struct {
+		Task string `json:"task"`
+	}
+	assert.ErrorIs(t, d.CheckType("Item", reflect.TypeOf(missing{})), openapi.ErrInvalid)
+
+	type wrongType struct {
+		Task int `json:"task"`
+	}
+	assert.ErrorIs(t, d.CheckType("NewItem", reflect.TypeOf(wrongType{})), openapi.ErrInvalid)
+}
@@ -2,16 +2,35 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"go-cmd-book/apis/todoClient/todoclient"
+	"go-cmd-book/apis/todorpc"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestMain(m *testing.M) {
+	// keep the tests away from the user cache, TestOffline enables it
+	viper.Set("cache-dir", "")
+	os.Exit(m.Run())
+}
+
 func mockServer(h http.HandlerFunc) (string, func()) {
 	ts := httptest.NewServer(h)
 	return ts.URL, ts.Close
@@ -28,7 +47,7 @@ func TestComplete(t *testing.T) {
 	})
 	defer cleanup()
 	var out bytes.Buffer
-	err := completeAction(&out, url, "1")
+	err := completeAction(&out, url, "1", "")
 	assert.NoError(t, err)
 	assert.Equal(t, "Action 1 completed\n", out.String())
 }
@@ -42,11 +61,35 @@ func TestDelete(t *testing.T) {
 	})
 	defer cleanup()
 	var out bytes.Buffer
-	err := deleteAction(&out, url, "1")
+	err := deleteAction(&out, url, "1", "")
 	assert.NoError(t, err)
 	assert.Equal(t, "Task id: 1 has been deleted\n", out.String())
 }
 
+func TestIfMatch(t *testing.T) {
+	url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Match") != `"v1"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer cleanup()
+
+	t.Run("Current", func(t *testing.T) {
+		var out bytes.Buffer
+		err := completeAction(&out, url, "1", `"v1"`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		var out bytes.Buffer
+		err := deleteAction(&out, url, "1", `"v0"`)
+		assert.ErrorIs(t, err, ErrPrecondition)
+		assert.Equal(t, "", out.String())
+	})
+}
+
 func TestView(t *testing.T) {
 	t.Run("ResultOne", func(t *testing.T) {
 		url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
@@ -67,11 +110,24 @@ func TestView(t *testing.T) {
 		})
 		defer cleanup()
 		out := bytes.Buffer{}
-		err := viewAction(&out, url, 1)
+		err := viewAction(&out, url, 1, false)
 		assert.NoError(t, err)
 		assert.Equal(t, "Task:         Task 1\nCreated:      03/06 @16:24\nCompleted:    No\n", out.String())
 	})
 
+	t.Run("ETag", func(t *testing.T) {
+		url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"abc"`)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"total_results": 1, "results": [{"task": "Task 1"}]}`)
+		})
+		defer cleanup()
+		out := bytes.Buffer{}
+		err := viewAction(&out, url, 1, true)
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), "ETag:         \"abc\"\n")
+	})
+
 }
 func TestAdd(t *testing.T) {
 	url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
@@ -120,11 +176,26 @@ func TestListAction(t *testing.T) {
 		defer cleanup()
 
 		out := bytes.Buffer{}
-		err := listAction(&out, url)
+		err := listAction(&out, url, 0, 0)
 		assert.NoError(t, err)
 		assert.Equal(t, "-  1  Task 1\n-  2  Task 2\n", out.String())
 	})
 
+	t.Run("Paginated", func(t *testing.T) {
+		url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "1", r.URL.Query().Get("offset"))
+			assert.Equal(t, "1", r.URL.Query().Get("limit"))
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"total_results": 2, "results": [{"task": "Task 2"}]}`)
+		})
+		defer cleanup()
+
+		out := bytes.Buffer{}
+		err := listAction(&out, url, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "-  2  Task 2\n", out.String())
+	})
+
 	t.Run("NoResults", func(t *testing.T) {
 		url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
@@ -140,7 +211,7 @@ func TestListAction(t *testing.T) {
 		defer cleanup()
 
 		out := bytes.Buffer{}
-		err := listAction(&out, url)
+		err := listAction(&out, url, 0, 0)
 		assert.True(t, errors.Is(err, ErrNotFound))
 		assert.Equal(t, "", out.String())
 	})
@@ -160,7 +231,650 @@ func TestListAction(t *testing.T) {
 
 		out := &bytes.Buffer{}
 		cleanup()
-		err := listAction(out, url)
+		err := listAction(out, url, 0, 0)
 		assert.ErrorIs(t, err, ErrConnection)
 	})
 }
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mu := sync.Mutex{}
+	conns := 0
+	url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/todo/events", r.URL.Path)
+		mu.Lock()
+		conns++
+		n := conns
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch n {
+		case 1:
+			assert.Equal(t, "", r.Header.Get("Last-Event-ID"))
+			fmt.Fprint(w, "retry: 10\n\n")
+			fmt.Fprint(w, "id: 1\nevent: add\ndata: {\"id\":1,\"type\":\"add\",\"item_id\":1,\"task\":\"Task 1\",\"date\":\"2024-06-03T16:24:49Z\"}\n\n")
+		default:
+			assert.Equal(t, "1", r.Header.Get("Last-Event-ID"))
+			fmt.Fprint(w, ": ping\n\n")
+			fmt.Fprint(w, "id: 2\nevent: complete\ndata: {\"id\":2,\"type\":\"complete\",\"item_id\":1,\"task\":\"Task 1\",\"date\":\"2024-06-03T16:25:49Z\"}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	})
+	defer cleanup()
+
+	out := bytes.Buffer{}
+	err := watchAction(ctx, cancelWriter{&out, cancel, "completed"}, url)
+	assert.NoError(t, err)
+	assert.Equal(t, "03/06 @16:24\t1\tadded \"Task 1\"\n03/06 @16:25\t1\tcompleted \"Task 1\"\n", out.String())
+}
+
+// cancelWriter cancels the watch once a line containing stop is written.
+type cancelWriter struct {
+	io.Writer
+	cancel context.CancelFunc
+	stop   string
+}
+
+func (w cancelWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if bytes.Contains(p, []byte(w.stop)) {
+		w.cancel()
+	}
+	return n, err
+}
+
+func TestRPCTransport(t *testing.T) {
+	viper.Set("transport", transportRPC)
+	defer viper.Set("transport", transportREST)
+
+	url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, todorpc.ContentTypeUnary, r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		w.Header().Set("Content-Type", todorpc.ContentTypeUnary)
+		switch r.URL.Path {
+		case todorpc.ListProcedure:
+			assert.JSONEq(t, `{"offset":0,"limit":0}`, string(body))
+			fmt.Fprint(w, `{"total_results":2,"items":[{"id":1,"task":"Task 1"},{"id":2,"task":"Task 2","done":true}]}`)
+		case todorpc.CompleteProcedure:
+			assert.JSONEq(t, `{"id":1,"if_match":"\"v0\""}`, string(body))
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"code":"failed_precondition","message":"item has been modified"}`)
+		case todorpc.DeleteProcedure:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"code":"not_found","message":"ID 9 not found"}`)
+		default:
+			t.Errorf("unexpected procedure %s", r.URL.Path)
+		}
+	})
+	defer cleanup()
+
+	t.Run("List", func(t *testing.T) {
+		out := bytes.Buffer{}
+		err := listAction(&out, url, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "-  1  Task 1\nX  2  Task 2\n", out.String())
+	})
+
+	t.Run("CompleteStale", func(t *testing.T) {
+		err := completeAction(&bytes.Buffer{}, url, "1", `"v0"`)
+		assert.ErrorIs(t, err, ErrPrecondition)
+	})
+
+	t.Run("DeleteNotFound", func(t *testing.T) {
+		err := deleteAction(&bytes.Buffer{}, url, "9", "")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+// fakeAPI is an in-memory todo API for tests that change the list.
+type fakeAPI struct {
+	mu    sync.Mutex
+	down  bool
+	tasks []string
+	done  map[string]bool
+}
+
+func (a *fakeAPI) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.down {
+			panic(http.ErrAbortHandler)
+		}
+
+		item := func(task string) map[string]any {
+			return map[string]any{"task": task, "done": a.done[task]}
+		}
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/todo/"))
+		switch {
+		case r.Method == http.MethodPost:
+			var i struct{ Task string }
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&i))
+			a.tasks = append(a.tasks, i.Task)
+			w.WriteHeader(http.StatusCreated)
+		case id == 0:
+			results := []map[string]any{}
+			for _, task := range a.tasks {
+				results = append(results, item(task))
+			}
+			json.NewEncoder(w).Encode(map[string]any{"total_results": len(a.tasks), "results": results})
+		case id > len(a.tasks):
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			w.Header().Set("ETag", fmt.Sprintf("%q", a.tasks[id-1]))
+			json.NewEncoder(w).Encode(map[string]any{"total_results": 1, "results": []any{item(a.tasks[id-1])}})
+		case r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != fmt.Sprintf("%q", a.tasks[id-1]):
+			w.WriteHeader(http.StatusPreconditionFailed)
+		case r.Method == http.MethodPatch:
+			if a.done == nil {
+				a.done = map[string]bool{}
+			}
+			if _, ok := r.URL.Query()["complete"]; ok {
+				a.done[a.tasks[id-1]] = true
+			} else {
+				var i struct{ Task string }
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&i))
+				if a.done[a.tasks[id-1]] {
+					delete(a.done, a.tasks[id-1])
+					a.done[i.Task] = true
+				}
+				a.tasks[id-1] = i.Task
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			a.tasks = append(a.tasks[:id-1], a.tasks[id:]...)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func TestOffline(t *testing.T) {
+	viper.Set("cache-dir", t.TempDir())
+	defer viper.Set("cache-dir", "")
+	clientOptions = []todoclient.Option{todoclient.WithRetries(0, 0)}
+	defer func() { clientOptions = nil }()
+
+	api := &fakeAPI{tasks: []string{"Task 1", "Task 2", "Task 3"}}
+	url, cleanup := mockServer(api.handler(t))
+	defer cleanup()
+	setDown := func(d bool) {
+		api.mu.Lock()
+		api.down = d
+		api.mu.Unlock()
+	}
+
+	out := bytes.Buffer{}
+	assert.NoError(t, listAction(&out, url, 0, 0))
+
+	setDown(true)
+
+	t.Run("List", func(t *testing.T) {
+		out := bytes.Buffer{}
+		assert.NoError(t, listAction(&out, url, 1, 1))
+		assert.Contains(t, out.String(), "-  2  Task 2\nOffline: showing the list cached at ")
+	})
+
+	t.Run("ListHugeLimit", func(t *testing.T) {
+		out := bytes.Buffer{}
+		assert.NoError(t, listAction(&out, url, 1, math.MaxInt))
+		assert.Contains(t, out.String(), "-  2  Task 2\n-  3  Task 3\nOffline: showing the list cached at ")
+	})
+
+	t.Run("Queue", func(t *testing.T) {
+		out := bytes.Buffer{}
+		assert.NoError(t, deleteAction(&out, url, "1", ""))
+		assert.NoError(t, addAction(&out, url, []string{"Task 4"}))
+		assert.NoError(t, deleteAction(&out, url, "2", ""))
+		assert.Equal(t, "Offline: delete \"Task 1\" queued until the server is reachable\n"+
+			"Offline: add \"Task 4\" queued until the server is reachable\n"+
+			"Offline: delete \"Task 3\" queued until the server is reachable\n", out.String())
+
+		err := deleteAction(&out, url, "9", "")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("View", func(t *testing.T) {
+		out := bytes.Buffer{}
+		assert.NoError(t, viewAction(&out, url, 2, true))
+		assert.Contains(t, out.String(), "Task:         Task 4\n")
+		assert.NotContains(t, out.String(), "ETag")
+	})
+
+	t.Run("SyncOffline", func(t *testing.T) {
+		err := syncAction(&bytes.Buffer{}, url)
+		assert.ErrorIs(t, err, ErrConnection)
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		api.mu.Lock()
+		// Task 3 was deleted by someone else in the meantime
+		api.tasks = []string{"Task 1", "Task 2"}
+		api.mu.Unlock()
+		setDown(false)
+
+		out := bytes.Buffer{}
+		err := syncAction(&out, url)
+		assert.ErrorIs(t, err, ErrConflict)
+		assert.Equal(t, "Conflict: conflict: cannot delete \"Task 3\", item 2 is now \"Task 4\"\n", out.String())
+		assert.Equal(t, []string{"Task 2", "Task 4"}, api.tasks)
+
+		out.Reset()
+		assert.NoError(t, syncAction(&out, url))
+		assert.Equal(t, "0 queued operations replayed\n", out.String())
+	})
+}
+
+func TestOfflineIfMatch(t *testing.T) {
+	viper.Set("cache-dir", t.TempDir())
+	defer viper.Set("cache-dir", "")
+	clientOptions = []todoclient.Option{todoclient.WithRetries(0, 0)}
+	defer func() { clientOptions = nil }()
+
+	api := &fakeAPI{tasks: []string{"Task 1", "Task 2"}}
+	url, cleanup := mockServer(api.handler(t))
+	defer cleanup()
+	assert.NoError(t, listAction(&bytes.Buffer{}, url, 0, 0))
+
+	api.mu.Lock()
+	api.down = true
+	api.mu.Unlock()
+	out := bytes.Buffer{}
+	// the fake API uses the quoted task as ETag
+	assert.NoError(t, completeAction(&out, url, "1", `"stale"`))
+	assert.NoError(t, deleteAction(&out, url, "2", `"Task 2"`))
+
+	api.mu.Lock()
+	api.down = false
+	api.mu.Unlock()
+	out.Reset()
+	err := syncAction(&out, url)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, "Conflict: conflict: cannot complete \"Task 1\", item 1 changed on the server\n", out.String())
+	assert.False(t, api.done["Task 1"])
+	assert.Equal(t, []string{"Task 1"}, api.tasks)
+}
+
+func TestOutput(t *testing.T) {
+	defer viper.Set("output", outputText)
+
+	url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `
+{
+	"total_results": 2,
+	"results": [
+		{"task": "Task 1", "done": false, "created_at": "2024-06-03T16:24:49Z"},
+		{"task": "Task, 2", "done": true, "created_at": "2024-06-03T16:24:53Z", "completed_at": "2024-06-04T10:00:00Z"}
+	]
+}`)
+	})
+	defer cleanup()
+
+	testCases := []struct {
+		name   string
+		output string
+		exp    string
+	}{
+		{"JSON", outputJSON, `[
+  {
+    "id": 1,
+    "task": "Task 1",
+    "done": false,
+    "created_at": "2024-06-03T16:24:49Z"
+  },
+  {
+    "id": 2,
+    "task": "Task, 2",
+    "done": true,
+    "created_at": "2024-06-03T16:24:53Z",
+    "completed_at": "2024-06-04T10:00:00Z"
+  }
+]
+`},
+		{"YAML", outputYAML, `- id: 1
+  task: Task 1
+  done: false
+  created_at: 2024-06-03T16:24:49Z
+- id: 2
+  task: Task, 2
+  done: true
+  created_at: 2024-06-03T16:24:53Z
+  completed_at: 2024-06-04T10:00:00Z
+`},
+		{"CSV", outputCSV, `id,task,done,created_at,completed_at
+1,Task 1,false,2024-06-03T16:24:49Z,
+2,"Task, 2",true,2024-06-03T16:24:53Z,2024-06-04T10:00:00Z
+`},
+		{"Template", "template={{.ID}}: {{.Task}}{{if .Done}} (done){{end}}", "1: Task 1\n2: Task, 2 (done)\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set("output", tc.output)
+			out := bytes.Buffer{}
+			err := listAction(&out, url, 0, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.exp, out.String())
+		})
+	}
+
+	t.Run("View", func(t *testing.T) {
+		url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"abc"`)
+			fmt.Fprint(w, `{"total_results": 1, "results": [{"task": "Task 1", "created_at": "2024-06-03T16:24:49Z"}]}`)
+		})
+		defer cleanup()
+
+		viper.Set("output", outputJSON)
+		out := bytes.Buffer{}
+		err := viewAction(&out, url, 1, false)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id": 1, "task": "Task 1", "done": false, "created_at": "2024-06-03T16:24:49Z", "etag": "\"abc\""}`, out.String())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"total_results": 0, "results": []}`)
+		})
+		defer cleanup()
+
+		viper.Set("output", outputJSON)
+		out := bytes.Buffer{}
+		assert.NoError(t, listAction(&out, url, 0, 0))
+		assert.Equal(t, "[]\n", out.String())
+	})
+
+	t.Run("Actions", func(t *testing.T) {
+		url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer cleanup()
+
+		viper.Set("output", outputJSON)
+		out := bytes.Buffer{}
+		assert.NoError(t, addAction(&out, url, []string{"Task", "1"}))
+		assert.JSONEq(t, `{"action": "add", "task": "Task 1", "queued": false}`, out.String())
+
+		out.Reset()
+		assert.NoError(t, completeAction(&out, url, "1", ""))
+		assert.JSONEq(t, `{"action": "complete", "id": 1, "queued": false}`, out.String())
+
+		viper.Set("output", outputCSV)
+		out.Reset()
+		assert.NoError(t, deleteAction(&out, url, "2", ""))
+		assert.Equal(t, "action,id,task,queued\ndelete,2,,false\n", out.String())
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		viper.Set("cache-dir", t.TempDir())
+		defer viper.Set("cache-dir", "")
+
+		viper.Set("output", outputJSON)
+		out := bytes.Buffer{}
+		assert.NoError(t, syncAction(&out, url))
+		assert.JSONEq(t, `{"replayed": 0, "conflicts": 0}`, out.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, output := range []string{"xml", "template={{.Task"} {
+			viper.Set("output", output)
+			err := listAction(&bytes.Buffer{}, url, 0, 0)
+			assert.ErrorIs(t, err, ErrOutput)
+		}
+	})
+}
+
+func TestTUI(t *testing.T) {
+	clientOptions = []todoclient.Option{todoclient.WithRetries(0, 0)}
+	defer func() { clientOptions = nil }()
+
+	api := &fakeAPI{tasks: []string{"Task 1", "Task 2", "Task 3"}}
+	url, cleanup := mockServer(api.handler(t))
+	defer cleanup()
+
+	// the fake terminal reads one key per write, like a real one in raw mode
+	in, keys := io.Pipe()
+	out := bytes.Buffer{}
+	tty := struct {
+		io.Reader
+		io.Writer
+	}{in, &out}
+	go func() {
+		for _, k := range []string{
+			keyDown, " ", // complete Task 2
+			"j", "d", "n", // cancel deleting Task 3
+			"d", "y", // delete Task 3
+			"a", "T", "a", "s", "k", "x", keyBackspace, " ", "4", keyEnter, // add Task 4
+			"k", "e", keyBackspace, "T", "w", "o", keyEnter, // edit Task 2
+			"q",
+		} {
+			keys.Write([]byte(k))
+		}
+		keys.Close()
+	}()
+
+	err := tuiAction(context.Background(), tty, url, 80, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Task 1", "Task Two", "Task 4"}, api.tasks)
+	assert.Equal(t, map[string]bool{"Task Two": true}, api.done)
+
+	frames := strings.Split(out.String(), clearScreen)
+	assert.Contains(t, frames, "")
+	seen := strings.Join(frames, "\n")
+	assert.Contains(t, seen, "Completed \"Task 2\"")
+	assert.Contains(t, seen, "Delete \"Task 3\"? (y/n)")
+	assert.Contains(t, seen, "Delete cancelled")
+	assert.Contains(t, seen, "New task: Task 4_")
+
+	last := frames[len(frames)-2]
+	assert.Contains(t, last, "-   1  Task 1\r\n")
+	assert.Contains(t, last, reverse+"X   2  Task Two"+resetStyle)
+	assert.Contains(t, last, "Edited \"Task Two\"")
+}
+
+func TestTUIWidth(t *testing.T) {
+	out := bytes.Buffer{}
+	ui := &tui{
+		c:      newClient("http://localhost:8080"),
+		out:    &out,
+		width:  12,
+		height: 10,
+		items:  []todoclient.Item{{ID: 1, Task: "A rather long task"}, {ID: 2, Task: "Tâche très longue"}},
+		cursor: 1,
+		status: "Completed \"A rather long task\"",
+	}
+	assert.NoError(t, ui.render())
+	assert.Equal(t, clearScreen+"Todo http://\r\n\r\n"+
+		"-   1  A rat\r\n"+
+		reverse+"-   2  Tâche"+resetStyle+"\r\n\r\n"+
+		"j/k move  sp\r\n"+
+		"Completed \"A", out.String())
+}
+
+func TestSplitKeys(t *testing.T) {
+	assert.Equal(t, []string{keyUp, "a", "é", keyEsc, keyDown}, splitKeys([]byte(keyUp+"aé"+keyEsc+keyDown)))
+}
+
+func TestProfiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "todoClient.yaml")
+
+	t.Run("Add", func(t *testing.T) {
+		out := bytes.Buffer{}
+		assert.NoError(t, profileAddAction(&out, file, "team", profile{APIRoot: "https://todo.team", Token: "secret"}))
+		assert.NoError(t, profileAddAction(&out, file, "staging", profile{APIRoot: "http://staging:8081", Transport: transportRPC}))
+		assert.Equal(t, "Added profile \"team\"\nAdded profile \"staging\"\n", out.String())
+
+		err := profileAddAction(&out, file, "team", profile{APIRoot: "https://todo.team"})
+		assert.ErrorIs(t, err, ErrProfileExists)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		testCases := []profile{
+			{APIRoot: "todo.team"},
+			{APIRoot: "https://todo.team", Transport: "grpc"},
+			{APIRoot: "https://todo.team", TLS: tlsSettings{CertFile: "client.pem"}},
+			{APIRoot: "https://todo.team", TLS: tlsSettings{CAFile: "missing.pem"}},
+		}
+		for _, p := range testCases {
+			err := profileAddAction(&bytes.Buffer{}, file, "broken", p)
+			assert.ErrorIs(t, err, ErrInvalidProfile)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		out := bytes.Buffer{}
+		assert.NoError(t, profileListAction(&out, file))
+		assert.Equal(t, "   staging  http://staging:8081  rpc\n*  team     https://todo.team    rest\n", out.String())
+	})
+
+	t.Run("Use", func(t *testing.T) {
+		out := bytes.Buffer{}
+		assert.NoError(t, profileUseAction(&out, file, "staging"))
+		assert.Equal(t, "Using profile \"staging\"\n", out.String())
+		assert.ErrorIs(t, profileUseAction(&out, file, "personal"), ErrNoProfile)
+
+		p, err := loadProfile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://staging:8081", p.APIRoot)
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		t.Setenv("TODO_PROFILE", "team")
+		p, err := loadProfile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://todo.team", p.APIRoot)
+
+		t.Setenv("TODO_PROFILE", "personal")
+		_, err = loadProfile(file)
+		assert.ErrorIs(t, err, ErrNoProfile)
+	})
+
+	t.Run("Validated", func(t *testing.T) {
+		broken := filepath.Join(t.TempDir(), "todoClient.yaml")
+		err := os.WriteFile(broken, []byte("current-profile: team\nprofiles:\n  team:\n    api-root: ftp://todo.team\n"), 0600)
+		assert.NoError(t, err)
+		_, err = loadProfile(broken)
+		assert.ErrorIs(t, err, ErrInvalidProfile)
+
+		// only the selected profile is validated
+		err = os.WriteFile(broken, []byte("current-profile: team\nprofiles:\n  team:\n    api-root: https://todo.team\n    tls:\n      insecure-skip-verify: true\n  old:\n    api-root: ftp://todo.team\n"), 0600)
+		assert.NoError(t, err)
+		p, err := loadProfile(broken)
+		assert.NoError(t, err)
+		assert.NotNil(t, p.rt)
+		assert.ErrorIs(t, profileUseAction(&bytes.Buffer{}, broken, "old"), ErrInvalidProfile)
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusCreated)
+		})
+		defer cleanup()
+
+		activeProfile = &profile{APIRoot: url, Token: "secret"}
+		defer func() { activeProfile = nil }()
+		assert.NoError(t, addAction(&bytes.Buffer{}, url, []string{"Task 1"}))
+	})
+}
+
+func TestConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "todoClient.yaml")
+
+	t.Run("Set", func(t *testing.T) {
+		out := bytes.Buffer{}
+		assert.NoError(t, configSetAction(&out, file, "output", "yaml"))
+		assert.NoError(t, configSetAction(&out, file, "transport", transportRPC))
+		assert.NoError(t, configSetAction(&out, file, "api-root", "http://file:8080"))
+		assert.Contains(t, out.String(), fmt.Sprintf("Set output to \"yaml\" in %s\n", file))
+
+		assert.ErrorIs(t, configSetAction(&out, file, "toggle", "true"), ErrConfigKey)
+		assert.ErrorIs(t, configSetAction(&out, file, "output", "xml"), ErrOutput)
+		assert.Error(t, configSetAction(&out, file, "transport", "grpc"))
+		assert.ErrorIs(t, configSetAction(&out, file, "current-profile", "team"), ErrNoProfile)
+
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "api-root: http://file:8080\noutput: yaml\ntransport: rpc\n", string(data))
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		flags := pflag.NewFlagSet("todoClient", pflag.ContinueOnError)
+		flags.String("api-root", "http://localhost:8080", "")
+		flags.String("transport", transportREST, "")
+		flags.String("output", outputText, "")
+		flags.String("cache-dir", "", "")
+		flags.String("profile", "", "")
+
+		v := viper.New()
+		v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+		v.SetEnvPrefix("TODO")
+		v.AutomaticEnv()
+		assert.NoError(t, v.BindPFlags(flags))
+		v.SetConfigFile(file)
+		assert.NoError(t, v.ReadInConfig())
+
+		assert.NoError(t, flags.Set("api-root", "http://flag:8080"))
+		t.Setenv("TODO_TRANSPORT", transportREST)
+
+		out := bytes.Buffer{}
+		assert.NoError(t, configViewAction(&out, file, v, flags))
+		assert.Equal(t, "Config file: "+file+"\n"+
+			"api-root         http://flag:8080  (flag)\n"+
+			"transport        rest              (env)\n"+
+			"output           yaml              (file)\n"+
+			"cache-dir                          (default)\n"+
+			"current-profile                    (default)\n", out.String())
+	})
+}
+
+func TestCompletion(t *testing.T) {
+	viper.Set("cache-dir", t.TempDir())
+	defer viper.Set("cache-dir", "")
+	defer func() { cfgFile = "" }()
+
+	lists := 0
+	api := &fakeAPI{tasks: []string{"Task 1", "Task 2", "Task 3"}, done: map[string]bool{"Task 2": true}}
+	h := api.handler(t)
+	url, cleanup := mockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/todo" {
+			lists++
+		}
+		h(w, r)
+	})
+	defer cleanup()
+
+	complete := func(args ...string) string {
+		out := bytes.Buffer{}
+		rootCmd.SetOut(&out)
+		defer rootCmd.SetOut(nil)
+		rootCmd.SetArgs(append([]string{cobra.ShellCompRequestCmd, "--config", filepath.Join(t.TempDir(), "none.yaml"), "--api-root", url}, args...))
+		assert.NoError(t, rootCmd.Execute())
+		return out.String()
+	}
+
+	assert.Equal(t, "1\tTask 1\n3\tTask 3\n:4\n", complete("complete", ""))
+	assert.Equal(t, "1\tTask 1\n2\tTask 2\n3\tTask 3\n:4\n", complete("delete", ""))
+	assert.Equal(t, "1\tTask 1\n2\tTask 2\n3\tTask 3\n:4\n", complete("view", "-i", ""))
+	assert.Equal(t, ":4\n", complete("delete", "1", ""))
+	// served from the cache
+	assert.Equal(t, 1, lists)
+
+	t.Run("NoCacheDir", func(t *testing.T) {
+		viper.Set("cache-dir", "")
+		lists = 0
+
+		assert.Equal(t, "1\tTask 1\n3\tTask 3\n:4\n", complete("complete", ""))
+		assert.Equal(t, "1\tTask 1\n2\tTask 2\n3\tTask 3\n:4\n", complete("delete", ""))
+		// nothing is written to disk, every completion fetches the list
+		assert.Equal(t, 2, lists)
+	})
+}
@@ -4,6 +4,7 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -26,9 +27,28 @@ var addCmd = &cobra.Command{
 
 func addAction(out io.Writer, apiRoot string, args []string) error {
 	task := strings.Join(args, " ")
-	if err := addItem(apiRoot, task); err != nil {
+	p, err := newPrinter(out)
+	if err != nil {
 		return err
 	}
+	ctx := context.Background()
+	c, s, err := connect(ctx, p.notes(), apiRoot)
+	if err != nil {
+		return err
+	}
+	op := &operation{Op: opAdd, Task: task}
+	queued, err := s.do(op, func() error {
+		return c.Add(ctx, task)
+	})
+	if err != nil {
+		return err
+	}
+	if !p.text() {
+		return printAction(p, *op, queued)
+	}
+	if queued {
+		return printQueued(out, *op)
+	}
 	return printAdd(out, task)
 }
 
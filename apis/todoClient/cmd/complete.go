@@ -4,6 +4,7 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -15,27 +16,52 @@ import (
 
 // completeCmd represents the complete command
 var completeCmd = &cobra.Command{
-	Use:   "complete <id>",
-	Short: "Complete a selected task",
+	Use:               "complete <id>",
+	Short:             "Complete a selected task",
+	ValidArgsFunction: completeIDs(true),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		apiRoot := viper.GetString("api-root")
-		return completeAction(os.Stdout, apiRoot, args[0])
+		ifMatch, err := cmd.Flags().GetString("if-match")
+		if err != nil {
+			return err
+		}
+		return completeAction(os.Stdout, apiRoot, args[0], ifMatch)
 	},
 }
 
-func completeAction(out io.Writer, apiRoot string, arg string) error {
+func completeAction(out io.Writer, apiRoot string, arg string, ifMatch string) error {
 	id, err := strconv.Atoi(arg)
 	if err != nil {
 		return fmt.Errorf("%w: item id must be a number", err)
 	}
-	if err := completeItem(apiRoot, id); err != nil {
+	p, err := newPrinter(out)
+	if err != nil {
 		return err
 	}
+	ctx := context.Background()
+	c, s, err := connect(ctx, p.notes(), apiRoot)
+	if err != nil {
+		return err
+	}
+	op := &operation{Op: opComplete, ID: id, IfMatch: ifMatch}
+	queued, err := s.do(op, func() error {
+		return c.Complete(ctx, id, ifMatch)
+	})
+	if err != nil {
+		return err
+	}
+	if !p.text() {
+		return printAction(p, *op, queued)
+	}
+	if queued {
+		return printQueued(out, *op)
+	}
 	_, err = fmt.Fprintf(out, "Action %d completed\n", id)
 	return err
 }
 
 func init() {
 	rootCmd.AddCommand(completeCmd)
+	completeCmd.Flags().String("if-match", "", "Only proceed if the item still has this ETag")
 
 }
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go-cmd-book/apis/todoClient/todoclient"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// completionTTL is how long a fetched list serves completions.
+	completionTTL     = 10 * time.Second
+	completionTimeout = 2 * time.Second
+)
+
+// completionItems returns the items to complete ids from. The list is
+// shared with the offline cache, and refetched once it is older than
+// completionTTL or when cache-dir is empty.
+func completionItems(apiRoot string) []todoclient.Item {
+	s, err := openStore(apiRoot)
+	if err != nil {
+		s = nil
+	}
+	// queued operations are only in the cache
+	if s.cached() && (len(s.Journal) > 0 || time.Since(s.Fetched) < completionTTL) {
+		return s.Items
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	c := newClient(apiRoot, todoclient.WithRetries(0, 0), todoclient.WithTimeout(completionTimeout))
+	page, err := c.List(ctx, 0, 0)
+	if err != nil {
+		if s.cached() {
+			return s.Items
+		}
+		return nil
+	}
+	s.update(page.Items)
+	return page.Items
+}
+
+// completeIDs completes the id of an item, annotated with its task. With
+// open set, completed items are left out.
+func completeIDs(open bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		if err := setup(cmd); err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		var ids []string
+		for _, i := range completionItems(viper.GetString("api-root")) {
+			if open && i.Done {
+				continue
+			}
+			ids = append(ids, fmt.Sprintf("%d\t%s", i.ID, i.Task))
+		}
+		return ids, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrConfigKey = errors.New("unknown config key")
+
+// configFile returns the path of the config file, which may not exist yet.
+func configFile() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("homedir missing: %w", err)
+	}
+	return filepath.Join(home, ".todoClient.yaml"), nil
+}
+
+// config is the content of the config file. It is edited as a document so
+// keys the client does not know about are kept.
+type config struct {
+	file string
+	doc  map[string]any
+}
+
+func readConfig(file string) (*config, error) {
+	c := &config{file: file, doc: map[string]any{}}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &c.doc); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	if c.doc == nil {
+		c.doc = map[string]any{}
+	}
+	return c, nil
+}
+
+func (c *config) save() error {
+	data := bytes.Buffer{}
+	enc := yaml.NewEncoder(&data)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.doc); err != nil {
+		return err
+	}
+	return os.WriteFile(c.file, data.Bytes(), 0600)
+}
+
+// settings are the keys of the config file. The value of each one comes,
+// from the highest priority, from its flag, its environment variable, the
+// selected profile, the config file or its default.
+var settings = []string{"api-root", "transport", "output", "cache-dir", "current-profile"}
+
+// profileSet records the settings applyProfile took from the profile.
+var profileSet = map[string]bool{}
+
+// settingSource returns where the value of key comes from.
+func settingSource(v *viper.Viper, flags *pflag.FlagSet, key string) string {
+	if f := flags.Lookup(key); f != nil && f.Changed {
+		return "flag"
+	}
+	if _, ok := os.LookupEnv(envVar(key)); ok {
+		return "env"
+	}
+	if profileSet[key] {
+		return "profile"
+	}
+	if v.InConfig(key) {
+		return "file"
+	}
+	return "default"
+}
+
+// validateSetting checks value before it is written to the config file.
+func validateSetting(key, value string) error {
+	switch key {
+	case "api-root":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("api-root %q must be an http or https URL", value)
+		}
+	case "transport":
+		if value != transportREST && value != transportRPC {
+			return fmt.Errorf("invalid transport %q, should be rest/rpc", value)
+		}
+	case "output":
+		_, err := parseOutput(value)
+		return err
+	case "cache-dir":
+	case "current-profile":
+	default:
+		return fmt.Errorf("%w %q, should be one of %v", ErrConfigKey, key, settings)
+	}
+	return nil
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and edit the config file",
+}
+
+var configViewCmd = &cobra.Command{
+	Use:          "view",
+	Short:        "Show the settings in effect and where they come from",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := configFile()
+		if err != nil {
+			return err
+		}
+		return configViewAction(os.Stdout, file, viper.GetViper(), cmd.Flags())
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:          "set <key> <value>",
+	Short:        "Write a setting to the config file",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	// the profiles are not loaded, so current-profile can replace a broken one
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := configFile()
+		if err != nil {
+			return err
+		}
+		return configSetAction(os.Stdout, file, args[0], args[1])
+	},
+}
+
+type settingView struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+func (settingView) header() []string {
+	return []string{"key", "value", "source"}
+}
+
+func (s settingView) row() []string {
+	return []string{s.Key, s.Value, s.Source}
+}
+
+func configViewAction(out io.Writer, file string, v *viper.Viper, flags *pflag.FlagSet) error {
+	p, err := newPrinter(out)
+	if err != nil {
+		return err
+	}
+	c, err := readConfig(file)
+	if err != nil {
+		return err
+	}
+
+	views := make([]settingView, 0, len(settings))
+	recs := make([]record, 0, len(settings))
+	for _, key := range settings {
+		s := settingView{Key: key, Value: v.GetString(key), Source: settingSource(v, flags, key)}
+		// --profile and TODO_PROFILE override the profile of the file
+		if name := v.GetString("profile"); key == "current-profile" && name != "" {
+			s.Value, s.Source = name, settingSource(v, flags, "profile")
+		} else if key == "current-profile" {
+			s.Value = c.current()
+		}
+		views = append(views, s)
+		recs = append(recs, s)
+	}
+	if !p.text() {
+		return p.print(views, recs...)
+	}
+
+	fmt.Fprintf(out, "Config file: %s\n", file)
+	w := tabwriter.NewWriter(out, 3, 2, 2, ' ', 0)
+	for _, s := range views {
+		fmt.Fprintf(w, "%s\t%s\t(%s)\n", s.Key, s.Value, s.Source)
+	}
+	return w.Flush()
+}
+
+func configSetAction(out io.Writer, file, key, value string) error {
+	if err := validateSetting(key, value); err != nil {
+		return err
+	}
+	c, err := readConfig(file)
+	if err != nil {
+		return err
+	}
+	if key == "current-profile" {
+		profiles, err := c.profiles()
+		if err != nil {
+			return err
+		}
+		p, ok := profiles[value]
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrNoProfile, value)
+		}
+		if err := p.load(value); err != nil {
+			return err
+		}
+	}
+	c.doc[key] = value
+	if err := c.save(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Set %s to %q in %s\n", key, value, file)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configViewCmd, configSetCmd)
+}
@@ -4,6 +4,7 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -15,27 +16,52 @@ import (
 
 // deleteCmd represents the delete command
 var deleteCmd = &cobra.Command{
-	Use:   "delete <id>",
-	Short: "Delete an item from the list",
+	Use:               "delete <id>",
+	Short:             "Delete an item from the list",
+	ValidArgsFunction: completeIDs(false),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		apiRoot := viper.GetString("api-root")
-		return deleteAction(os.Stdout, apiRoot, args[0])
+		ifMatch, err := cmd.Flags().GetString("if-match")
+		if err != nil {
+			return err
+		}
+		return deleteAction(os.Stdout, apiRoot, args[0], ifMatch)
 	},
 }
 
-func deleteAction(out io.Writer, apiRoot string, arg string) error {
+func deleteAction(out io.Writer, apiRoot string, arg string, ifMatch string) error {
 	id, err := strconv.Atoi(arg)
 	if err != nil {
 		return fmt.Errorf("%w: id must be a number", err)
 	}
 
-	if err := deleteItem(apiRoot, id); err != nil {
+	p, err := newPrinter(out)
+	if err != nil {
 		return err
 	}
+	ctx := context.Background()
+	c, s, err := connect(ctx, p.notes(), apiRoot)
+	if err != nil {
+		return err
+	}
+	op := &operation{Op: opDelete, ID: id, IfMatch: ifMatch}
+	queued, err := s.do(op, func() error {
+		return c.Delete(ctx, id, ifMatch)
+	})
+	if err != nil {
+		return err
+	}
+	if !p.text() {
+		return printAction(p, *op, queued)
+	}
+	if queued {
+		return printQueued(out, *op)
+	}
 
 	_, err = fmt.Fprintf(out, "Task id: %d has been deleted\n", id)
 	return err
 }
 func init() {
 	rootCmd.AddCommand(deleteCmd)
+	deleteCmd.Flags().String("if-match", "", "Only proceed if the item still has this ETag")
 }
@@ -4,7 +4,10 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"go-cmd-book/apis/todoClient/todoclient"
 	"io"
 	"os"
 	"text/tabwriter"
@@ -20,32 +23,82 @@ var listCmd = &cobra.Command{
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		apiRoot := viper.GetString("api-root")
-		return listAction(os.Stdout, apiRoot)
+		offset, err := cmd.Flags().GetInt("offset")
+		if err != nil {
+			return err
+		}
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			return err
+		}
+		return listAction(os.Stdout, apiRoot, offset, limit)
 	},
 }
 
-func listAction(out io.Writer, apiRoot string) error {
-	items, err := getAll(apiRoot)
+func listAction(out io.Writer, apiRoot string, offset, limit int) error {
+	p, err := newPrinter(out)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	c, s, err := connect(ctx, p.notes(), apiRoot)
 	if err != nil {
 		return err
 	}
-	return printAll(out, items)
+	page, err := c.List(ctx, offset, limit)
+	offline := errors.Is(err, ErrConnection) && s.cached()
+	switch {
+	case offline:
+		page = s.page(offset, limit)
+	case err != nil:
+		return err
+	case offset == 0 && limit == 0:
+		if err := s.update(page.Items); err != nil {
+			return err
+		}
+	}
+	if !p.text() {
+		return printItems(p, page.Items)
+	}
+	if page.TotalResults == 0 {
+		return fmt.Errorf("%w: No results found", ErrNotFound)
+	}
+	if err := printAll(out, page.Items); err != nil {
+		return err
+	}
+	if offline {
+		return printOffline(out, s)
+	}
+	return nil
+}
+
+func printItems(p *printer, items []todoclient.Item) error {
+	views := make([]itemView, 0, len(items))
+	recs := make([]record, 0, len(items))
+	for _, i := range items {
+		v := newItemView(i)
+		views = append(views, v)
+		recs = append(recs, v)
+	}
+	return p.print(views, recs...)
 }
 
-func printAll(out io.Writer, items []item) error {
+func printAll(out io.Writer, items []todoclient.Item) error {
 	w := tabwriter.NewWriter(out, 3, 2, 0, ' ', 0)
-	for k, v := range items {
+	for _, v := range items {
 		done := "-"
 		if v.Done {
 			done = "X"
 		}
-		fmt.Fprintf(w, "%s\t%d\t%s\t\n", done, k+1, v.Task)
+		fmt.Fprintf(w, "%s\t%d\t%s\t\n", done, v.ID, v.Task)
 	}
 	return w.Flush()
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().Int("offset", 0, "Number of items to skip")
+	listCmd.Flags().Int("limit", 0, "Maximum number of items to list (0 lists all)")
 
 	// Here you will define your flags and configuration settings.
 
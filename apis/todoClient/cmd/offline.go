@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-cmd-book/apis/todoClient/todoclient"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var ErrConflict = errors.New("conflict")
+
+const (
+	opAdd      = "add"
+	opComplete = "complete"
+	opDelete   = "delete"
+)
+
+// operation is a write made while offline. ID and Task are those the user
+// saw, Task is checked against the server before replaying. IfMatch is the
+// ETag the user made the write conditional on.
+type operation struct {
+	Op      string    `json:"op"`
+	ID      int       `json:"id,omitempty"`
+	Task    string    `json:"task"`
+	IfMatch string    `json:"if_match,omitempty"`
+	Queued  time.Time `json:"queued"`
+}
+
+// offlineStore keeps the last fetched list of an API root and the journal
+// of operations waiting for the server. Queued operations are applied to the
+// cached list so it reflects what the user did offline.
+type offlineStore struct {
+	file    string
+	Items   []todoclient.Item `json:"items"`
+	Fetched time.Time         `json:"fetched"`
+	Journal []operation       `json:"journal"`
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "todoClient")
+}
+
+// openStore loads the store of apiRoot. It returns nil when offline mode is
+// disabled by an empty cache-dir.
+func openStore(apiRoot string) (*offlineStore, error) {
+	dir := viper.GetString("cache-dir")
+	if dir == "" {
+		return nil, nil
+	}
+	s := &offlineStore{file: filepath.Join(dir, url.QueryEscape(apiRoot)+".json")}
+	data, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid offline cache %s: %w", s.file, err)
+	}
+	s.renumber()
+	return s, nil
+}
+
+func (s *offlineStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.file), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.file, data, 0600)
+}
+
+// renumber sets the ids of the cached items, they are list positions.
+func (s *offlineStore) renumber() {
+	for i := range s.Items {
+		s.Items[i].ID = i + 1
+	}
+}
+
+func (s *offlineStore) cached() bool {
+	return s != nil && !s.Fetched.IsZero()
+}
+
+// update replaces the cached list. It is skipped while operations are
+// pending, since the server does not reflect them yet.
+func (s *offlineStore) update(items []todoclient.Item) error {
+	if s == nil || len(s.Journal) > 0 {
+		return nil
+	}
+	s.Items = items
+	s.Fetched = time.Now()
+	s.renumber()
+	return s.save()
+}
+
+func (s *offlineStore) item(id int) (todoclient.Item, error) {
+	if id <= 0 || id > len(s.Items) {
+		return todoclient.Item{}, fmt.Errorf("%w: item %d is not in the offline cache", ErrNotFound, id)
+	}
+	return s.Items[id-1], nil
+}
+
+// page returns the cached items like the server would for offset and limit.
+func (s *offlineStore) page(offset, limit int) *todoclient.Page {
+	start := min(offset, len(s.Items))
+	end := len(s.Items)
+	if limit > 0 {
+		end = start + min(limit, end-start)
+	}
+	return &todoclient.Page{Items: s.Items[start:end], TotalResults: len(s.Items)}
+}
+
+// apply makes op on the cached list.
+func (s *offlineStore) apply(op operation) error {
+	switch op.Op {
+	case opAdd:
+		s.Items = append(s.Items, todoclient.Item{Task: op.Task, CreatedAt: op.Queued})
+	case opComplete:
+		if _, err := s.item(op.ID); err != nil {
+			return err
+		}
+		s.Items[op.ID-1].Done = true
+		s.Items[op.ID-1].CompletedAt = op.Queued
+	case opDelete:
+		if _, err := s.item(op.ID); err != nil {
+			return err
+		}
+		s.Items = append(s.Items[:op.ID-1], s.Items[op.ID:]...)
+	}
+	s.renumber()
+	return nil
+}
+
+// do runs send for op, or queues op when the server is unreachable or
+// earlier operations are still waiting. It reports whether op was queued.
+func (s *offlineStore) do(op *operation, send func() error) (bool, error) {
+	op.Queued = time.Now()
+	if s == nil {
+		return false, send()
+	}
+	if op.Op != opAdd {
+		i, err := s.item(op.ID)
+		if err == nil {
+			op.Task = i.Task
+		}
+	}
+
+	if len(s.Journal) == 0 {
+		err := send()
+		if !errors.Is(err, ErrConnection) {
+			if err == nil && s.apply(*op) == nil {
+				err = s.save()
+			}
+			return false, err
+		}
+		if op.Op != opAdd && !s.cached() {
+			return false, err
+		}
+	}
+
+	if err := s.apply(*op); err != nil {
+		return false, err
+	}
+	s.Journal = append(s.Journal, *op)
+	return true, s.save()
+}
+
+// replay sends the queued operations in order. Operations that conflict with
+// the server are reported to out and dropped. It stops at the first
+// connection error, keeping the rest of the journal.
+func (s *offlineStore) replay(ctx context.Context, out io.Writer, c *todoclient.Client) (int, error) {
+	conflicts := 0
+	for s != nil && len(s.Journal) > 0 {
+		op := s.Journal[0]
+		err := replayOp(ctx, c, op)
+		if errors.Is(err, ErrConnection) {
+			return conflicts, err
+		}
+		if err != nil {
+			conflicts++
+			fmt.Fprintf(out, "Conflict: %s\n", err)
+		}
+		s.Journal = s.Journal[1:]
+		if err := s.save(); err != nil {
+			return conflicts, err
+		}
+	}
+	return conflicts, nil
+}
+
+func replayOp(ctx context.Context, c *todoclient.Client, op operation) error {
+	if op.Op == opAdd {
+		return c.Add(ctx, op.Task)
+	}
+
+	i, err := c.Get(ctx, op.ID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return fmt.Errorf("%w: cannot %s %q, item %d was deleted on the server", ErrConflict, op.Op, op.Task, op.ID)
+	case err != nil:
+		return err
+	case i.Task != op.Task:
+		return fmt.Errorf("%w: cannot %s %q, item %d is now %q", ErrConflict, op.Op, op.Task, op.ID, i.Task)
+	case op.IfMatch != "" && op.IfMatch != i.ETag:
+		return fmt.Errorf("%w: cannot %s %q, item %d changed on the server", ErrConflict, op.Op, op.Task, op.ID)
+	}
+
+	ifMatch := op.IfMatch
+	if ifMatch == "" {
+		ifMatch = i.ETag
+	}
+	if op.Op == opComplete {
+		if i.Done {
+			return nil
+		}
+		err = c.Complete(ctx, op.ID, ifMatch)
+	} else {
+		err = c.Delete(ctx, op.ID, ifMatch)
+	}
+	if errors.Is(err, ErrPrecondition) || errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: cannot %s %q, item %d changed on the server", ErrConflict, op.Op, op.Task, op.ID)
+	}
+	return err
+}
+
+// connect returns a client for apiRoot and its offline store, after
+// replaying the operations queued while offline.
+func connect(ctx context.Context, out io.Writer, apiRoot string) (*todoclient.Client, *offlineStore, error) {
+	c := newClient(apiRoot)
+	s, err := openStore(apiRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.replay(ctx, out, c); err != nil && !errors.Is(err, ErrConnection) {
+		return nil, nil, err
+	}
+	return c, s, nil
+}
+
+func printQueued(out io.Writer, op operation) error {
+	_, err := fmt.Fprintf(out, "Offline: %s %q queued until the server is reachable\n", op.Op, op.Task)
+	return err
+}
+
+func printOffline(out io.Writer, s *offlineStore) error {
+	_, err := fmt.Fprintf(out, "Offline: showing the list cached at %s\n", s.Fetched.Format(timeFormat))
+	return err
+}
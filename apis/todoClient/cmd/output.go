@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-cmd-book/apis/todoClient/todoclient"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrOutput = errors.New("invalid output format")
+
+const (
+	outputText     = "text"
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputCSV      = "csv"
+	templatePrefix = "template="
+)
+
+// record is a value printable as a CSV row.
+type record interface {
+	header() []string
+	row() []string
+}
+
+type itemView struct {
+	ID          int        `json:"id" yaml:"id"`
+	Task        string     `json:"task" yaml:"task"`
+	Done        bool       `json:"done" yaml:"done"`
+	CreatedAt   time.Time  `json:"created_at" yaml:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" yaml:"completed_at,omitempty"`
+	ETag        string     `json:"etag,omitempty" yaml:"etag,omitempty"`
+}
+
+func newItemView(i todoclient.Item) itemView {
+	v := itemView{
+		ID:        i.ID,
+		Task:      i.Task,
+		Done:      i.Done,
+		CreatedAt: i.CreatedAt,
+		ETag:      i.ETag,
+	}
+	if i.Done {
+		v.CompletedAt = &i.CompletedAt
+	}
+	return v
+}
+
+func (itemView) header() []string {
+	return []string{"id", "task", "done", "created_at", "completed_at"}
+}
+
+func (v itemView) row() []string {
+	completed := ""
+	if v.CompletedAt != nil {
+		completed = v.CompletedAt.Format(time.RFC3339)
+	}
+	return []string{strconv.Itoa(v.ID), v.Task, strconv.FormatBool(v.Done), v.CreatedAt.Format(time.RFC3339), completed}
+}
+
+type eventView struct {
+	ID     int64     `json:"id" yaml:"id"`
+	Type   string    `json:"type" yaml:"type"`
+	ItemID int       `json:"item_id" yaml:"item_id"`
+	Task   string    `json:"task" yaml:"task"`
+	Date   time.Time `json:"date" yaml:"date"`
+}
+
+func (eventView) header() []string {
+	return []string{"id", "type", "item_id", "task", "date"}
+}
+
+func (v eventView) row() []string {
+	return []string{strconv.FormatInt(v.ID, 10), v.Type, strconv.Itoa(v.ItemID), v.Task, v.Date.Format(time.RFC3339)}
+}
+
+// actionView is the result of a write: add, complete or delete.
+type actionView struct {
+	Action string `json:"action" yaml:"action"`
+	ID     int    `json:"id,omitempty" yaml:"id,omitempty"`
+	Task   string `json:"task,omitempty" yaml:"task,omitempty"`
+	Queued bool   `json:"queued" yaml:"queued"`
+}
+
+func (actionView) header() []string {
+	return []string{"action", "id", "task", "queued"}
+}
+
+func (v actionView) row() []string {
+	id := ""
+	if v.ID > 0 {
+		id = strconv.Itoa(v.ID)
+	}
+	return []string{v.Action, id, v.Task, strconv.FormatBool(v.Queued)}
+}
+
+func printAction(p *printer, op operation, queued bool) error {
+	v := actionView{Action: op.Op, ID: op.ID, Task: op.Task, Queued: queued}
+	return p.print(v, v)
+}
+
+type syncView struct {
+	Replayed  int `json:"replayed" yaml:"replayed"`
+	Conflicts int `json:"conflicts" yaml:"conflicts"`
+}
+
+func (syncView) header() []string {
+	return []string{"replayed", "conflicts"}
+}
+
+func (v syncView) row() []string {
+	return []string{strconv.Itoa(v.Replayed), strconv.Itoa(v.Conflicts)}
+}
+
+// printer writes results in the format selected by the output setting.
+// Structured formats write one document per call, CSV and templates one
+// line per record, so a printer can be reused for a stream of results.
+type printer struct {
+	out    io.Writer
+	format string
+	tmpl   *template.Template
+	json   *json.Encoder
+	yaml   *yaml.Encoder
+	csv    *csv.Writer
+}
+
+func newPrinter(out io.Writer) (*printer, error) {
+	p := &printer{out: out, format: viper.GetString("output")}
+	tmpl, err := parseOutput(p.format)
+	if err != nil {
+		return nil, err
+	}
+	p.tmpl = tmpl
+	return p, nil
+}
+
+// parseOutput validates format, returning its template if it has one.
+func parseOutput(format string) (*template.Template, error) {
+	switch {
+	case format == outputText, format == outputJSON, format == outputYAML, format == outputCSV:
+		return nil, nil
+	case strings.HasPrefix(format, templatePrefix):
+		tmpl, err := template.New("output").Parse(strings.TrimPrefix(format, templatePrefix))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrOutput, err)
+		}
+		return tmpl, nil
+	}
+	return nil, fmt.Errorf("%w %q, should be text/json/yaml/csv/template=<template>", ErrOutput, format)
+}
+
+// text reports whether the default human readable output is selected.
+func (p *printer) text() bool {
+	return p.format == outputText
+}
+
+// notes returns where to write messages that are not results, like replay
+// conflicts: the output for text, stderr otherwise so structured output
+// stays parseable.
+func (p *printer) notes() io.Writer {
+	if p.text() {
+		return p.out
+	}
+	return os.Stderr
+}
+
+// print writes v, whose records are recs.
+func (p *printer) print(v any, recs ...record) error {
+	switch {
+	case p.format == outputJSON:
+		if p.json == nil {
+			p.json = json.NewEncoder(p.out)
+			p.json.SetIndent("", "  ")
+		}
+		return p.json.Encode(v)
+	case p.format == outputYAML:
+		if p.yaml == nil {
+			p.yaml = yaml.NewEncoder(p.out)
+			p.yaml.SetIndent(2)
+		}
+		return p.yaml.Encode(v)
+	case p.format == outputCSV:
+		if p.csv == nil {
+			p.csv = csv.NewWriter(p.out)
+			if len(recs) > 0 {
+				p.csv.Write(recs[0].header())
+			}
+		}
+		for _, r := range recs {
+			p.csv.Write(r.row())
+		}
+		p.csv.Flush()
+		return p.csv.Error()
+	case p.tmpl != nil:
+		for _, r := range recs {
+			if err := p.tmpl.Execute(p.out, r); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(p.out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w %q", ErrOutput, p.format)
+}
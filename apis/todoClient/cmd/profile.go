@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidProfile = errors.New("invalid profile")
+	ErrNoProfile      = errors.New("profile not found")
+	ErrProfileExists  = errors.New("profile already exists")
+)
+
+type tlsSettings struct {
+	CAFile             string `yaml:"ca-file,omitempty"`
+	CertFile           string `yaml:"cert-file,omitempty"`
+	KeyFile            string `yaml:"key-file,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify,omitempty"`
+}
+
+// profile is a server the client can talk to, stored in the config file
+// under profiles.
+type profile struct {
+	APIRoot   string      `yaml:"api-root"`
+	Token     string      `yaml:"token,omitempty"`
+	Transport string      `yaml:"transport,omitempty"`
+	TLS       tlsSettings `yaml:"tls,omitempty"`
+
+	// rt is the round tripper built from TLS by load.
+	rt http.RoundTripper
+}
+
+// activeProfile is set by the root command when a profile is selected.
+var activeProfile *profile
+
+func (p profile) validate() error {
+	u, err := url.Parse(p.APIRoot)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("api-root %q must be an http or https URL", p.APIRoot)
+	}
+	if p.Transport != "" && p.Transport != transportREST && p.Transport != transportRPC {
+		return fmt.Errorf("invalid transport %q, should be rest/rpc", p.Transport)
+	}
+	if (p.TLS.CertFile == "") != (p.TLS.KeyFile == "") {
+		return errors.New("tls cert-file and key-file must be set together")
+	}
+	return nil
+}
+
+// load validates p named name and builds its TLS transport, reading the
+// files it refers to.
+func (p *profile) load(name string) error {
+	err := p.validate()
+	if err == nil {
+		p.rt, err = p.TLS.transport()
+	}
+	if err != nil {
+		return fmt.Errorf("%w %q: %s", ErrInvalidProfile, name, err)
+	}
+	return nil
+}
+
+// transport returns the round tripper for the TLS settings, nil when they
+// are all empty.
+func (s tlsSettings) transport() (http.RoundTripper, error) {
+	if s == (tlsSettings{}) {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: s.InsecureSkipVerify}
+	if s.CAFile != "" {
+		pem, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.CAFile)
+		}
+	}
+	if s.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg
+	return t, nil
+}
+
+// profiles returns the profiles of the file. They are not validated, the
+// one in use is by load.
+func (c *config) profiles() (map[string]profile, error) {
+	profiles := map[string]profile{}
+	raw, err := yaml.Marshal(c.doc["profiles"])
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(raw, &profiles); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProfile, err)
+	}
+	if profiles == nil {
+		profiles = map[string]profile{}
+	}
+	return profiles, nil
+}
+
+func (c *config) current() string {
+	name, _ := c.doc["current-profile"].(string)
+	return name
+}
+
+// loadProfile reads the profile selected by --profile, TODO_PROFILE or the
+// current-profile of the config file. It returns nil when none is selected.
+func loadProfile(file string) (*profile, error) {
+	c, err := readConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	name := viper.GetString("profile")
+	if name == "" {
+		name = c.current()
+	}
+	if name == "" {
+		return nil, nil
+	}
+	profiles, err := c.profiles()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNoProfile, name)
+	}
+	if err := p.load(name); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// applyProfile makes p the source of api-root and transport, unless they
+// are set with a flag or the environment.
+func applyProfile(cmd *cobra.Command, p *profile) {
+	activeProfile = p
+	profileSet = map[string]bool{}
+	if p == nil {
+		return
+	}
+	set := func(key, value string) {
+		if value == "" || cmd.Flags().Changed(key) {
+			return
+		}
+		if _, ok := os.LookupEnv(envVar(key)); ok {
+			return
+		}
+		viper.Set(key, value)
+		profileSet[key] = true
+	}
+	set("api-root", p.APIRoot)
+	set("transport", p.Transport)
+}
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage the servers the client talks to",
+	// the profiles are not loaded, so a broken one can still be replaced
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		_, err := newPrinter(io.Discard)
+		return err
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the profiles of the config file",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := configFile()
+		if err != nil {
+			return err
+		}
+		return profileListAction(os.Stdout, file)
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:          "use <name>",
+	Short:        "Make a profile the default one",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := configFile()
+		if err != nil {
+			return err
+		}
+		return profileUseAction(os.Stdout, file, args[0])
+	},
+}
+
+var profileAddCmd = &cobra.Command{
+	Use:          "add <name>",
+	Short:        "Add a profile to the config file",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := configFile()
+		if err != nil {
+			return err
+		}
+		p := profile{}
+		f := cmd.Flags()
+		p.APIRoot, _ = f.GetString("url")
+		p.Token, _ = f.GetString("token")
+		p.TLS.CAFile, _ = f.GetString("ca-file")
+		p.TLS.CertFile, _ = f.GetString("cert-file")
+		p.TLS.KeyFile, _ = f.GetString("key-file")
+		p.TLS.InsecureSkipVerify, _ = f.GetBool("insecure")
+		if f.Changed("transport") {
+			p.Transport, _ = f.GetString("transport")
+		}
+		return profileAddAction(os.Stdout, file, args[0], p)
+	},
+}
+
+type profileView struct {
+	Name      string `json:"name" yaml:"name"`
+	APIRoot   string `json:"api_root" yaml:"api_root"`
+	Transport string `json:"transport" yaml:"transport"`
+	Current   bool   `json:"current" yaml:"current"`
+}
+
+func (profileView) header() []string {
+	return []string{"name", "api_root", "transport", "current"}
+}
+
+func (v profileView) row() []string {
+	return []string{v.Name, v.APIRoot, v.Transport, strconv.FormatBool(v.Current)}
+}
+
+func profileListAction(out io.Writer, file string) error {
+	p, err := newPrinter(out)
+	if err != nil {
+		return err
+	}
+	c, err := readConfig(file)
+	if err != nil {
+		return err
+	}
+	profiles, err := c.profiles()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	views := make([]profileView, 0, len(names))
+	recs := make([]record, 0, len(names))
+	for _, name := range names {
+		transport := profiles[name].Transport
+		if transport == "" {
+			transport = transportREST
+		}
+		v := profileView{Name: name, APIRoot: profiles[name].APIRoot, Transport: transport, Current: name == c.current()}
+		views = append(views, v)
+		recs = append(recs, v)
+	}
+	if !p.text() {
+		return p.print(views, recs...)
+	}
+
+	w := tabwriter.NewWriter(out, 3, 2, 2, ' ', 0)
+	for _, v := range views {
+		current := " "
+		if v.Current {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, v.Name, v.APIRoot, v.Transport)
+	}
+	return w.Flush()
+}
+
+func profileUseAction(out io.Writer, file, name string) error {
+	c, err := readConfig(file)
+	if err != nil {
+		return err
+	}
+	profiles, err := c.profiles()
+	if err != nil {
+		return err
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNoProfile, name)
+	}
+	if err := p.load(name); err != nil {
+		return err
+	}
+	c.doc["current-profile"] = name
+	if err := c.save(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Using profile %q\n", name)
+	return err
+}
+
+func profileAddAction(out io.Writer, file, name string, p profile) error {
+	if err := p.load(name); err != nil {
+		return err
+	}
+	c, err := readConfig(file)
+	if err != nil {
+		return err
+	}
+	profiles, err := c.profiles()
+	if err != nil {
+		return err
+	}
+	if _, ok := profiles[name]; ok {
+		return fmt.Errorf("%w: %q", ErrProfileExists, name)
+	}
+	profiles[name] = p
+	c.doc["profiles"] = profiles
+	if len(profiles) == 1 {
+		c.doc["current-profile"] = name
+	}
+	if err := c.save(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Added profile %q\n", name)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd, profileUseCmd, profileAddCmd)
+
+	profileAddCmd.Flags().String("url", "", "API url of the server")
+	profileAddCmd.Flags().String("token", "", "Bearer token sent to the server")
+	profileAddCmd.Flags().String("ca-file", "", "CA certificates to verify the server with")
+	profileAddCmd.Flags().String("cert-file", "", "Client certificate")
+	profileAddCmd.Flags().String("key-file", "", "Client certificate key")
+	profileAddCmd.Flags().Bool("insecure", false, "Skip the verification of the server certificate")
+	profileAddCmd.MarkFlagRequired("url")
+}
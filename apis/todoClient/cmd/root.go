@@ -4,6 +4,10 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"strings"
 
@@ -11,10 +15,15 @@ import (
 	"github.com/spf13/viper"
 )
 
+var cfgFile string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "todoClient",
 	Short: "A Todo api client",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return setup(cmd)
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -30,11 +39,64 @@ func Execute() {
 }
 
 func init() {
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	cobra.OnInitialize(initConfig)
+
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.todoClient.yaml)")
+	rootCmd.PersistentFlags().String("profile", "", "Profile of the config file to use, overrides current-profile")
 	rootCmd.PersistentFlags().String("api-root", "http://localhost:8080", "Todo API url")
+	rootCmd.PersistentFlags().String("transport", transportREST, "API to use: rest, or rpc with api-root set to the server RPC port")
+	rootCmd.PersistentFlags().StringP("output", "o", outputText, "Output format: text, json, yaml, csv or template=<Go template> applied to each item")
+	rootCmd.PersistentFlags().String("cache-dir", defaultCacheDir(), "Directory of the offline cache and queue, empty disables offline mode")
 	replacer := strings.NewReplacer("-", "_")
 	viper.SetEnvKeyReplacer(replacer)
 	viper.SetEnvPrefix("TODO")
 
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindEnv("profile")
 	viper.BindPFlag("api-root", rootCmd.PersistentFlags().Lookup("api-root"))
+	viper.BindPFlag("transport", rootCmd.PersistentFlags().Lookup("transport"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("cache-dir", rootCmd.PersistentFlags().Lookup("cache-dir"))
+}
+
+// setup applies the selected profile and validates the settings of cmd.
+func setup(cmd *cobra.Command) error {
+	file, err := configFile()
+	if err != nil {
+		return err
+	}
+	p, err := loadProfile(file)
+	if err != nil {
+		return err
+	}
+	applyProfile(cmd, p)
+
+	transport := viper.GetString("transport")
+	if transport != transportREST && transport != transportRPC {
+		return fmt.Errorf("invalid transport %q, should be rest/rpc", transport)
+	}
+	_, err = newPrinter(io.Discard)
+	return err
+}
+
+func initConfig() {
+	file, err := configFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	viper.SetConfigFile(file)
+	viper.SetConfigType("yaml")
+
+	viper.AutomaticEnv()
+
+	if err := viper.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("invalid config file %s: %w", file, err))
+		os.Exit(1)
+	}
+}
+
+// envVar returns the environment variable that sets key.
+func envVar(key string) string {
+	return "TODO_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
 }
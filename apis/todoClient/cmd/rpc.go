@@ -0,0 +1,14 @@
+package cmd
+
+import "github.com/spf13/viper"
+
+const (
+	transportREST = "rest"
+	transportRPC  = "rpc"
+)
+
+// useRPC reports whether commands talk to the RPC API instead of REST.
+// api-root must then point at the RPC port of the server.
+func useRPC() bool {
+	return viper.GetString("transport") == transportRPC
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:          "sync",
+	Short:        "Replay the operations queued while offline",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiRoot := viper.GetString("api-root")
+		return syncAction(os.Stdout, apiRoot)
+	},
+}
+
+func syncAction(out io.Writer, apiRoot string) error {
+	p, err := newPrinter(out)
+	if err != nil {
+		return err
+	}
+	s, err := openStore(apiRoot)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return fmt.Errorf("offline mode is disabled, set cache-dir to enable it")
+	}
+	pending := len(s.Journal)
+	conflicts, err := s.replay(context.Background(), p.notes(), newClient(apiRoot))
+	if err != nil {
+		return fmt.Errorf("%w: %d operations still queued", err, len(s.Journal))
+	}
+	if !p.text() {
+		v := syncView{Replayed: pending - conflicts, Conflicts: conflicts}
+		if err := p.print(v, v); err != nil {
+			return err
+		}
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%w: %d of %d operations dropped", ErrConflict, conflicts, pending)
+	}
+	if !p.text() {
+		return nil
+	}
+	_, err = fmt.Fprintf(out, "%d queued operations replayed\n", pending)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+}
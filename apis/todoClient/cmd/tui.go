@@ -0,0 +1,402 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-cmd-book/apis/todoClient/todoclient"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	reverse     = "\x1b[7m"
+	resetStyle  = "\x1b[0m"
+
+	keyUp        = "\x1b[A"
+	keyDown      = "\x1b[B"
+	keyEnter     = "\r"
+	keyEsc       = "\x1b"
+	keyBackspace = "\x7f"
+	keyCtrlC     = "\x03"
+)
+
+type tuiMode int
+
+const (
+	modeBrowse tuiMode = iota
+	modeAdd
+	modeEdit
+	modeConfirmDelete
+)
+
+// tuiCmd represents the tui command
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Manage the list in a full-screen interactive mode",
+	Long: `Manage the list in a full-screen interactive mode.
+
+Keys: up/down or k/j move, space completes, a adds, e edits, d deletes,
+r refreshes and q quits.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiRoot := viper.GetString("api-root")
+		refresh, err := cmd.Flags().GetDuration("refresh")
+		if err != nil {
+			return err
+		}
+
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			return errors.New("tui needs an interactive terminal")
+		}
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+
+		width, height := 80, 24
+		if w, h, err := term.GetSize(fd); err == nil {
+			width, height = w, h
+		}
+		tty := struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}
+		return tuiAction(cmd.Context(), tty, apiRoot, width, height, refresh)
+	},
+}
+
+// tui is the state of the interactive mode. It only changes in the loop of
+// tuiAction, so it needs no locking.
+type tui struct {
+	ctx    context.Context
+	c      *todoclient.Client
+	out    io.Writer
+	width  int
+	height int
+
+	items  []todoclient.Item
+	cursor int
+	mode   tuiMode
+	input  []rune
+	status string
+}
+
+// tuiAction runs the interactive mode on tty until the user quits or ctx
+// is cancelled. tty must already be in raw mode.
+func tuiAction(ctx context.Context, tty io.ReadWriter, apiRoot string, width, height int, refresh time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t := &tui{ctx: ctx, c: newClient(apiRoot), out: tty, width: width, height: height}
+	keys := make(chan string)
+	errCh := make(chan error, 1)
+	go readKeys(ctx, tty, keys, errCh)
+
+	var tick <-chan time.Time
+	if refresh > 0 {
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	t.refresh()
+	for {
+		if err := t.render(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errCh:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-tick:
+			if t.mode == modeBrowse {
+				t.refresh()
+			}
+		case k := <-keys:
+			if !t.handle(k) {
+				_, err := io.WriteString(t.out, clearScreen)
+				return err
+			}
+		}
+	}
+}
+
+// readKeys sends every key read from r. A read returns whole escape
+// sequences on terminals, so each one is split into keys by splitKeys.
+func readKeys(ctx context.Context, r io.Reader, keys chan<- string, errCh chan<- error) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, k := range splitKeys(buf[:n]) {
+			select {
+			case keys <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			errCh <- err
+			return
+		}
+	}
+}
+
+func splitKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		if b[0] == '\x1b' && len(b) >= 3 && b[1] == '[' {
+			keys = append(keys, string(b[:3]))
+			b = b[3:]
+			continue
+		}
+		_, size := utf8.DecodeRune(b)
+		keys = append(keys, string(b[:size]))
+		b = b[size:]
+	}
+	return keys
+}
+
+// handle applies key and reports whether the loop should go on.
+func (t *tui) handle(key string) bool {
+	if key == keyCtrlC {
+		return false
+	}
+	switch t.mode {
+	case modeAdd, modeEdit:
+		t.handleInput(key)
+	case modeConfirmDelete:
+		if key == "y" {
+			t.delete()
+		} else {
+			t.status = "Delete cancelled"
+		}
+		t.mode = modeBrowse
+	default:
+		return t.handleBrowse(key)
+	}
+	return true
+}
+
+func (t *tui) handleBrowse(key string) bool {
+	t.status = ""
+	switch key {
+	case "q":
+		return false
+	case keyUp, "k":
+		t.cursor = max(t.cursor-1, 0)
+	case keyDown, "j":
+		t.cursor = min(t.cursor+1, max(len(t.items)-1, 0))
+	case "r":
+		t.refresh()
+	case "a":
+		t.mode = modeAdd
+		t.input = nil
+	case " ", "e", "d":
+		i, ok := t.selected()
+		if !ok {
+			return true
+		}
+		switch key {
+		case " ":
+			t.complete(i)
+		case "e":
+			t.mode = modeEdit
+			t.input = []rune(i.Task)
+		case "d":
+			t.mode = modeConfirmDelete
+			t.status = fmt.Sprintf("Delete %q? (y/n)", i.Task)
+		}
+	}
+	return true
+}
+
+func (t *tui) handleInput(key string) {
+	switch key {
+	case keyEsc:
+		t.mode = modeBrowse
+	case keyBackspace, "\b":
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	case keyEnter, "\n":
+		task := strings.TrimSpace(string(t.input))
+		mode := t.mode
+		t.mode = modeBrowse
+		if task == "" {
+			return
+		}
+		if mode == modeAdd {
+			t.add(task)
+		} else {
+			t.edit(task)
+		}
+	default:
+		r, _ := utf8.DecodeRuneInString(key)
+		if utf8.RuneCountInString(key) == 1 && unicode.IsPrint(r) {
+			t.input = append(t.input, r)
+		}
+	}
+}
+
+func (t *tui) selected() (todoclient.Item, bool) {
+	if t.cursor >= len(t.items) {
+		return todoclient.Item{}, false
+	}
+	return t.items[t.cursor], true
+}
+
+func (t *tui) refresh() {
+	page, err := t.c.List(t.ctx, 0, 0)
+	if err != nil {
+		t.status = fmt.Sprintf("Refresh failed: %s", err)
+		return
+	}
+	t.items = page.Items
+	t.cursor = min(t.cursor, max(len(t.items)-1, 0))
+}
+
+// current fetches i again so writes use its ETag and never hit an item
+// that took its place since the last refresh.
+func (t *tui) current(i todoclient.Item) (todoclient.Item, bool) {
+	cur, err := t.c.Get(t.ctx, i.ID)
+	if err == nil && cur.Task != i.Task {
+		err = fmt.Errorf("%w: item %d changed on the server", ErrPrecondition, i.ID)
+	}
+	if err != nil {
+		t.status = err.Error()
+		t.refresh()
+		return cur, false
+	}
+	return cur, true
+}
+
+// done reports the result of a write and reloads the list.
+func (t *tui) done(err error, msg string) {
+	t.refresh()
+	if err != nil {
+		t.status = err.Error()
+		return
+	}
+	t.status = msg
+}
+
+func (t *tui) complete(i todoclient.Item) {
+	if i.Done {
+		t.status = fmt.Sprintf("%q is already completed", i.Task)
+		return
+	}
+	cur, ok := t.current(i)
+	if !ok {
+		return
+	}
+	t.done(t.c.Complete(t.ctx, i.ID, cur.ETag), fmt.Sprintf("Completed %q", i.Task))
+}
+
+func (t *tui) add(task string) {
+	err := t.c.Add(t.ctx, task)
+	t.done(err, fmt.Sprintf("Added %q", task))
+	if err == nil {
+		t.cursor = max(len(t.items)-1, 0)
+	}
+}
+
+func (t *tui) edit(task string) {
+	i, ok := t.selected()
+	if !ok || task == i.Task {
+		return
+	}
+	cur, ok := t.current(i)
+	if !ok {
+		return
+	}
+	t.done(t.c.Edit(t.ctx, i.ID, task, cur.ETag), fmt.Sprintf("Edited %q", task))
+}
+
+func (t *tui) delete() {
+	i, ok := t.selected()
+	if !ok {
+		return
+	}
+	cur, ok := t.current(i)
+	if !ok {
+		return
+	}
+	t.done(t.c.Delete(t.ctx, i.ID, cur.ETag), fmt.Sprintf("Deleted %q", i.Task))
+}
+
+// render draws the whole screen. Raw mode needs explicit carriage returns.
+func (t *tui) render() error {
+	var b strings.Builder
+	b.WriteString(clearScreen)
+	// lines are cut to the width, wrapped ones would scroll the screen
+	line := func(s string) {
+		b.WriteString(t.fit(s) + "\r\n")
+	}
+	line(fmt.Sprintf("Todo %s (%d items)", t.c.BaseURL(), len(t.items)))
+	line("")
+
+	// header, blank line, and three lines of footer
+	rows := max(t.height-5, 1)
+	first := max(t.cursor-rows+1, 0)
+	for k := first; k < len(t.items) && k < first+rows; k++ {
+		i := t.items[k]
+		done := "-"
+		if i.Done {
+			done = "X"
+		}
+		s := t.fit(fmt.Sprintf("%s %3d  %s", done, i.ID, i.Task))
+		if k == t.cursor {
+			s = reverse + s + resetStyle
+		}
+		b.WriteString(s + "\r\n")
+	}
+	if len(t.items) == 0 {
+		line("No items, press a to add one")
+	}
+
+	line("")
+	switch t.mode {
+	case modeAdd:
+		line(fmt.Sprintf("New task: %s_", string(t.input)))
+	case modeEdit:
+		line(fmt.Sprintf("Edit task: %s_", string(t.input)))
+	default:
+		line("j/k move  space complete  a add  e edit  d delete  r refresh  q quit")
+	}
+	b.WriteString(t.fit(t.status))
+	_, err := io.WriteString(t.out, b.String())
+	return err
+}
+
+// fit cuts s to the width of the terminal. A width of zero leaves it whole.
+func (t *tui) fit(s string) string {
+	if t.width <= 0 {
+		return s
+	}
+	r := []rune(s)
+	if len(r) <= t.width {
+		return s
+	}
+	return string(r[:t.width])
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+	tuiCmd.Flags().Duration("refresh", 5*time.Second, "How often to reload the list, 0 disables it")
+}
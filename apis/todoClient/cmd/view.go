@@ -4,7 +4,10 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"go-cmd-book/apis/todoClient/todoclient"
 	"io"
 	"os"
 	"text/tabwriter"
@@ -25,19 +28,51 @@ var viewCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return viewAction(os.Stdout, apiRoot, id)
+		showETag, err := cmd.Flags().GetBool("etag")
+		if err != nil {
+			return err
+		}
+		return viewAction(os.Stdout, apiRoot, id, showETag)
 	},
 }
 
-func viewAction(out io.Writer, apiRoot string, id int) error {
-	item, err := getOne(apiRoot, id)
+func viewAction(out io.Writer, apiRoot string, id int, showETag bool) error {
+	p, err := newPrinter(out)
 	if err != nil {
 		return err
 	}
-	return printOne(out, item)
+	ctx := context.Background()
+	c, s, err := connect(ctx, p.notes(), apiRoot)
+	if err != nil {
+		return err
+	}
+	item, err := c.Get(ctx, id)
+	offline := errors.Is(err, ErrConnection) && s.cached()
+	if offline {
+		item, err = s.item(id)
+	}
+	if err != nil {
+		return err
+	}
+	if !p.text() {
+		v := newItemView(item)
+		return p.print(v, v)
+	}
+	if err := printOne(out, item); err != nil {
+		return err
+	}
+	if showETag && !offline {
+		if _, err := fmt.Fprintf(out, "ETag:         %s\n", item.ETag); err != nil {
+			return err
+		}
+	}
+	if offline {
+		return printOffline(out, s)
+	}
+	return nil
 }
 
-func printOne(out io.Writer, i item) error {
+func printOne(out io.Writer, i todoclient.Item) error {
 	w := tabwriter.NewWriter(out, 14, 2, 0, ' ', 0)
 	fmt.Fprintf(w, "Task:\t%s\n", i.Task)
 	fmt.Fprintf(w, "Created:\t%s\n", i.CreatedAt.Format(timeFormat))
@@ -55,4 +90,6 @@ func init() {
 	rootCmd.AddCommand(viewCmd)
 	viewCmd.Flags().IntP("id", "i", 0, "Item ID")
 	viewCmd.MarkFlagRequired("id")
+	viewCmd.RegisterFlagCompletionFunc("id", completeIDs(false))
+	viewCmd.Flags().Bool("etag", false, "Show the item version, usable with --if-match")
 }
@@ -0,0 +1,67 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go-cmd-book/apis/todoClient/todoclient"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:          "watch",
+	Short:        "Print changes to the list as they happen",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiRoot := viper.GetString("api-root")
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		return watchAction(ctx, os.Stdout, apiRoot)
+	},
+}
+
+// watchAction streams events until ctx is cancelled, reconnecting with the
+// last seen event id whenever the stream drops.
+func watchAction(ctx context.Context, out io.Writer, apiRoot string) error {
+	p, err := newPrinter(out)
+	if err != nil {
+		return err
+	}
+	return newClient(apiRoot).Watch(ctx, 0, func(e todoclient.Event) error {
+		if !p.text() {
+			v := eventView(e)
+			return p.print(v, v)
+		}
+		return printEvent(out, e)
+	})
+}
+
+func printEvent(out io.Writer, e todoclient.Event) error {
+	var msg string
+	switch e.Type {
+	case "add":
+		msg = "added"
+	case "complete":
+		msg = "completed"
+	case "delete":
+		msg = "deleted"
+	case "edit":
+		msg = "renamed to"
+	default:
+		msg = e.Type
+	}
+	_, err := fmt.Fprintf(out, "%s\t%d\t%s %q\n", e.Date.Format(timeFormat), e.ItemID, msg, e.Task)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,397 @@
+// Package todoclient is a Go client for the todoServer API.
+//
+// It speaks the REST API by default and the RPC API with WithRPC. Reads are
+// retried with exponential backoff on connection errors, rate limiting and
+// unavailable servers. Writes conditional on an ETag are retried only when
+// the server refused them, by rate limiting or as unavailable: after a lost
+// response the write may have been applied, and sending it again would fail
+// with ErrPrecondition. Other writes are sent once.
+package todoclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-cmd-book/apis/todorpc"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+	DefaultRetries = 3
+	DefaultBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+type Item struct {
+	ID          int       `json:"-"`
+	Task        string    `json:"task"`
+	Done        bool      `json:"done"`
+	CreatedAt   time.Time `json:"created_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	// ETag is set by Get and can be passed to Complete and Delete.
+	ETag string `json:"-"`
+}
+
+type Page struct {
+	Items        []Item
+	TotalResults int
+	ETag         string
+}
+
+type newItem struct {
+	Task string `json:"task"`
+}
+
+type response struct {
+	Results      []Item `json:"results"`
+	Date         int    `json:"date"`
+	TotalResults int    `json:"total_results"`
+}
+
+type Client struct {
+	baseURL string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+	rpc     *todorpc.Client
+	token   string
+	// stream has no timeout, for Watch
+	stream *http.Client
+}
+
+type Option func(*Client)
+
+// WithTimeout sets the timeout of each request. Watch is not affected.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.http.Timeout = d
+	}
+}
+
+// WithTransport sets the round tripper used for every request.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.http.Transport = rt
+		c.stream.Transport = rt
+	}
+}
+
+// WithRetries sets how many times a safe call is retried and the initial
+// backoff, doubled after every attempt. Zero retries disables retrying.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithToken sends token as a bearer token with every request.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRPC makes the client use the RPC API. The base URL must then point at
+// the RPC port of the server.
+func WithRPC() Option {
+	return func(c *Client) {
+		c.rpc = &todorpc.Client{}
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: DefaultTimeout},
+		stream:  &http.Client{},
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.token != "" {
+		c.http.Transport = &tokenTransport{base: c.http.Transport, token: c.token}
+		c.stream.Transport = &tokenTransport{base: c.stream.Transport, token: c.token}
+	}
+	if c.rpc != nil {
+		c.rpc = todorpc.NewClient(c.baseURL, c.http)
+	}
+	return c
+}
+
+// BaseURL returns the API root the client talks to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// tokenTransport adds the Authorization header, so the RPC client and the
+// event streams send it as well.
+type tokenTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return base.RoundTrip(r)
+}
+
+type request struct {
+	method    string
+	path      string
+	header    http.Header
+	body      []byte
+	expStatus int
+	// retry reports the errors the request is sent again on, nil sends it
+	// once
+	retry func(error) bool
+}
+
+// do sends req, retrying it when allowed, and returns the response with the
+// expected status. The caller closes the body.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	var r *http.Response
+	err := c.withRetry(ctx, req.retry, func() error {
+		var err error
+		r, err = c.send(ctx, req)
+		return err
+	})
+	return r, err
+}
+
+// withRetry calls f until it succeeds, fails with an error retry rejects or
+// the retries are exhausted. A nil retry attempts f once.
+func (c *Client) withRetry(ctx context.Context, retry func(error) bool, f func() error) error {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || retry == nil || attempt >= c.retries || !retry(err) {
+			return err
+		}
+
+		wait := backoff
+		var se *StatusError
+		if errors.As(err, &se) && se.RetryAfter > wait {
+			wait = se.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", ctx.Err(), ErrConnection)
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	hr, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.header {
+		hr.Header[k] = v
+	}
+
+	r, err := c.http.Do(hr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err, ErrConnection)
+	}
+	if r.StatusCode != req.expStatus {
+		defer r.Body.Close()
+		return nil, newStatusError(r)
+	}
+	return r, nil
+}
+
+func (c *Client) Add(ctx context.Context, task string) error {
+	if c.rpc != nil {
+		_, err := c.rpc.Add(ctx, &todorpc.AddRequest{Task: task})
+		return rpcError(err)
+	}
+
+	body := bytes.Buffer{}
+	if err := json.NewEncoder(&body).Encode(newItem{Task: task}); err != nil {
+		return err
+	}
+	r, err := c.do(ctx, request{
+		method:    http.MethodPost,
+		path:      "/todo",
+		header:    http.Header{"Content-Type": {"application/json"}},
+		body:      body.Bytes(),
+		expStatus: http.StatusCreated,
+	})
+	if err != nil {
+		return err
+	}
+	return r.Body.Close()
+}
+
+// Complete marks item id done. With a non-empty ifMatch it fails with
+// ErrPrecondition if the item changed since its ETag was read.
+func (c *Client) Complete(ctx context.Context, id int, ifMatch string) error {
+	if c.rpc != nil {
+		return c.withRetry(ctx, writeRetry(ifMatch), func() error {
+			_, err := c.rpc.Complete(ctx, &todorpc.CompleteRequest{ID: id, IfMatch: ifMatch})
+			return rpcError(err)
+		})
+	}
+	return c.modify(ctx, http.MethodPatch, fmt.Sprintf("/todo/%d?complete", id), ifMatch, nil)
+}
+
+// Edit renames item id to task, keeping its position and done state. With a
+// non-empty ifMatch it fails with ErrPrecondition if the item changed since
+// its ETag was read.
+func (c *Client) Edit(ctx context.Context, id int, task, ifMatch string) error {
+	if c.rpc != nil {
+		return c.withRetry(ctx, writeRetry(ifMatch), func() error {
+			_, err := c.rpc.Edit(ctx, &todorpc.EditRequest{ID: id, Task: task, IfMatch: ifMatch})
+			return rpcError(err)
+		})
+	}
+	body := bytes.Buffer{}
+	if err := json.NewEncoder(&body).Encode(newItem{Task: task}); err != nil {
+		return err
+	}
+	return c.modify(ctx, http.MethodPatch, fmt.Sprintf("/todo/%d", id), ifMatch, body.Bytes())
+}
+
+// Delete removes item id. With a non-empty ifMatch it fails with
+// ErrPrecondition if the item changed since its ETag was read.
+func (c *Client) Delete(ctx context.Context, id int, ifMatch string) error {
+	if c.rpc != nil {
+		return c.withRetry(ctx, writeRetry(ifMatch), func() error {
+			_, err := c.rpc.Delete(ctx, &todorpc.DeleteRequest{ID: id, IfMatch: ifMatch})
+			return rpcError(err)
+		})
+	}
+	return c.modify(ctx, http.MethodDelete, fmt.Sprintf("/todo/%d", id), ifMatch, nil)
+}
+
+// writeRetry returns the errors a write is sent again on. Ids are positions,
+// so replaying an unconditional write could hit another item.
+func writeRetry(ifMatch string) func(error) bool {
+	if ifMatch == "" {
+		return nil
+	}
+	return refused
+}
+
+func (c *Client) modify(ctx context.Context, method, path, ifMatch string, body []byte) error {
+	req := request{
+		method:    method,
+		path:      path,
+		header:    http.Header{},
+		body:      body,
+		expStatus: http.StatusNoContent,
+		retry:     writeRetry(ifMatch),
+	}
+	if ifMatch != "" {
+		req.header.Set("If-Match", ifMatch)
+	}
+	if body != nil {
+		req.header.Set("Content-Type", "application/json")
+	}
+	r, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	return r.Body.Close()
+}
+
+// List returns a page of items starting after offset. A zero limit returns
+// every remaining item.
+func (c *Client) List(ctx context.Context, offset, limit int) (*Page, error) {
+	if c.rpc != nil {
+		return c.rpcList(ctx, offset, limit)
+	}
+
+	q := url.Values{}
+	if offset > 0 {
+		q.Set("offset", strconv.Itoa(offset))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/todo"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	resp, etag, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp.Results {
+		resp.Results[i].ID = offset + i + 1
+	}
+	return &Page{
+		Items:        resp.Results,
+		TotalResults: resp.TotalResults,
+		ETag:         etag,
+	}, nil
+}
+
+func (c *Client) Get(ctx context.Context, id int) (Item, error) {
+	if c.rpc != nil {
+		var resp *todorpc.GetResponse
+		err := c.withRetry(ctx, retryable, func() error {
+			var err error
+			resp, err = c.rpc.Get(ctx, &todorpc.GetRequest{ID: id})
+			return rpcError(err)
+		})
+		if err != nil {
+			return Item{}, err
+		}
+		return fromRPC(resp.Item), nil
+	}
+
+	resp, etag, err := c.get(ctx, fmt.Sprintf("/todo/%d", id))
+	if err != nil {
+		return Item{}, err
+	}
+	if len(resp.Results) != 1 {
+		return Item{}, fmt.Errorf("%w: Invalid result", ErrInvalidData)
+	}
+	i := resp.Results[0]
+	i.ID = id
+	i.ETag = etag
+	return i, nil
+}
+
+func (c *Client) get(ctx context.Context, path string) (*response, string, error) {
+	r, err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      path,
+		expStatus: http.StatusOK,
+		retry:     retryable,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Body.Close()
+
+	resp := &response{}
+	if err := json.NewDecoder(r.Body).Decode(resp); err != nil {
+		return nil, "", fmt.Errorf("%w: Invalid json response: %s", ErrInvalidResponse, err)
+	}
+	return resp, r.Header.Get("ETag"), nil
+}
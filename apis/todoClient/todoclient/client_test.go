@@ -0,0 +1,196 @@
+package todoclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flaky fails the first n requests with status, then serves h.
+func flaky(n int32, status int, h http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+		h(w, r)
+	}))
+	return ts, calls
+}
+
+func TestRetry(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"total_results": 2, "results": [{"task": "Task 2"}]}`)
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		ts, calls := flaky(2, http.StatusServiceUnavailable, ok)
+		defer ts.Close()
+
+		c := New(ts.URL, WithRetries(3, time.Millisecond))
+		p, err := c.List(context.Background(), 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, 2, p.TotalResults)
+		assert.Equal(t, `"v1"`, p.ETag)
+		assert.Equal(t, []Item{{ID: 2, Task: "Task 2"}}, p.Items)
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		ts, calls := flaky(10, http.StatusServiceUnavailable, ok)
+		defer ts.Close()
+
+		c := New(ts.URL, WithRetries(2, time.Millisecond))
+		_, err := c.Get(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrUnavailable)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("UnconditionalWrite", func(t *testing.T) {
+		ts, calls := flaky(1, http.StatusServiceUnavailable, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer ts.Close()
+
+		c := New(ts.URL, WithRetries(3, time.Millisecond))
+		err := c.Delete(context.Background(), 1, "")
+		assert.ErrorIs(t, err, ErrUnavailable)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("ConditionalWrite", func(t *testing.T) {
+		ts, calls := flaky(1, http.StatusTooManyRequests, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, `"v1"`, r.Header.Get("If-Match"))
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer ts.Close()
+
+		c := New(ts.URL, WithRetries(3, time.Millisecond))
+		err := c.Complete(context.Background(), 1, `"v1"`)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("ConditionalWriteLost", func(t *testing.T) {
+		calls := &atomic.Int32{}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				// applied, but the response never arrives
+				panic(http.ErrAbortHandler)
+			}
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}))
+		defer ts.Close()
+
+		c := New(ts.URL, WithRetries(3, time.Millisecond))
+		err := c.Complete(context.Background(), 1, `"v1"`)
+		assert.ErrorIs(t, err, ErrConnection)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		ts, calls := flaky(1, http.StatusNotFound, ok)
+		defer ts.Close()
+
+		c := New(ts.URL, WithRetries(3, time.Millisecond))
+		_, err := c.Get(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ts, _ := flaky(10, http.StatusServiceUnavailable, ok)
+		defer ts.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		c := New(ts.URL, WithRetries(3, time.Hour))
+		_, err := c.List(ctx, 0, 0)
+		assert.ErrorIs(t, err, ErrConnection)
+	})
+}
+
+func TestStatusError(t *testing.T) {
+	testCases := []struct {
+		status int
+		exp    error
+	}{
+		{http.StatusBadRequest, ErrInvalidData},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusPreconditionFailed, ErrPrecondition},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusBadGateway, ErrUnavailable},
+		{http.StatusInternalServerError, ErrInvalidResponse},
+	}
+	for _, tc := range testCases {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(tc.status)
+				fmt.Fprintln(w, "reason")
+			}))
+			defer ts.Close()
+
+			err := New(ts.URL, WithRetries(0, 0)).Add(context.Background(), "Task 1")
+			assert.ErrorIs(t, err, tc.exp)
+
+			var se *StatusError
+			assert.True(t, errors.As(err, &se))
+			assert.Equal(t, tc.status, se.StatusCode)
+			assert.Equal(t, "reason", se.Message)
+			assert.Equal(t, 7*time.Second, se.RetryAfter)
+		})
+	}
+}
+
+func TestConnectionError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	err := New(ts.URL, WithRetries(0, 0), WithTimeout(time.Second)).Add(context.Background(), "Task 1")
+	assert.ErrorIs(t, err, ErrConnection)
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/todo/events", r.URL.Path)
+		assert.Equal(t, "5", r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 6\nevent: delete\ndata: {\"id\":6,\"type\":\"delete\",\"item_id\":2,\"task\":\"Task 2\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	var got []Event
+	err := New(ts.URL).Watch(ctx, 5, func(e Event) error {
+		got = append(got, e)
+		cancel()
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Event{{ID: 6, Type: "delete", ItemID: 2, Task: "Task 2"}}, got)
+}
+
+func TestToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	err := New(ts.URL, WithTransport(http.DefaultTransport), WithToken("secret")).Add(context.Background(), "Task 1")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,71 @@
+package todoclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrConnection      = errors.New("connection Error")
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidResponse = errors.New("invalid response")
+	ErrInvalidData     = errors.New("invalid data")
+	ErrPrecondition    = errors.New("precondition failed")
+	ErrRateLimited     = errors.New("rate limited")
+	ErrUnavailable     = errors.New("service unavailable")
+)
+
+// StatusError is returned when the server answers with an unexpected
+// status. It matches one of the package errors with errors.Is.
+type StatusError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay the server asked for, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.Unwrap(), e.StatusCode, e.Message)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return ErrInvalidData
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusPreconditionFailed:
+		return ErrPrecondition
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrUnavailable
+	}
+	return ErrInvalidResponse
+}
+
+func newStatusError(r *http.Response) *StatusError {
+	e := &StatusError{StatusCode: r.StatusCode}
+	if msg, err := io.ReadAll(r.Body); err == nil {
+		e.Message = strings.TrimSpace(string(msg))
+	}
+	if secs, err := strconv.Atoi(r.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
+
+func retryable(err error) bool {
+	return errors.Is(err, ErrConnection) || refused(err)
+}
+
+// refused reports whether the server turned the request down without
+// handling it.
+func refused(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable)
+}
@@ -0,0 +1,59 @@
+package todoclient
+
+import (
+	"context"
+	"fmt"
+	"go-cmd-book/apis/todorpc"
+)
+
+// rpcError maps RPC codes onto the errors of the package.
+func rpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch todorpc.CodeOf(err) {
+	case todorpc.CodeNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, err)
+	case todorpc.CodeFailedPrecondition:
+		return fmt.Errorf("%w: %s", ErrPrecondition, err)
+	case todorpc.CodeInvalidArgument:
+		return fmt.Errorf("%w: %s", ErrInvalidData, err)
+	case todorpc.CodeResourceExhausted:
+		return fmt.Errorf("%w: %s", ErrRateLimited, err)
+	case todorpc.CodeUnavailable:
+		return fmt.Errorf("%s: %w", err, ErrConnection)
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidResponse, err)
+}
+
+func fromRPC(i todorpc.Item) Item {
+	return Item{
+		ID:          i.ID,
+		Task:        i.Task,
+		Done:        i.Done,
+		CreatedAt:   i.CreatedAt,
+		CompletedAt: i.CompletedAt,
+		ETag:        i.ETag,
+	}
+}
+
+func (c *Client) rpcList(ctx context.Context, offset, limit int) (*Page, error) {
+	var resp *todorpc.ListResponse
+	err := c.withRetry(ctx, retryable, func() error {
+		var err error
+		resp, err = c.rpc.List(ctx, &todorpc.ListRequest{Offset: offset, Limit: limit})
+		return rpcError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Page{
+		Items:        make([]Item, 0, len(resp.Items)),
+		TotalResults: resp.TotalResults,
+	}
+	for _, i := range resp.Items {
+		p.Items = append(p.Items, fromRPC(i))
+	}
+	return p, nil
+}
@@ -0,0 +1,29 @@
+package todoclient
+
+import (
+	"go-cmd-book/apis/openapi"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecConformance(t *testing.T) {
+	d, err := openapi.Load()
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		schema string
+		value  any
+	}{
+		{"Item", Item{}},
+		{"NewItem", newItem{}},
+		{"TodoResponse", response{}},
+		{"Event", Event{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.schema, func(t *testing.T) {
+			assert.NoError(t, d.CheckType(tc.schema, reflect.TypeOf(tc.value)))
+		})
+	}
+}
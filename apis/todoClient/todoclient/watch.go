@@ -0,0 +1,130 @@
+package todoclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-cmd-book/apis/todorpc"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultWatchRetry = 2 * time.Second
+
+type Event struct {
+	ID     int64     `json:"id"`
+	Type   string    `json:"type"`
+	ItemID int       `json:"item_id"`
+	Task   string    `json:"task"`
+	Date   time.Time `json:"date"`
+}
+
+// Watch calls handle for every change after lastID until ctx is cancelled,
+// reconnecting with the last seen event id whenever the stream drops. It
+// returns nil on cancellation and the error of handle if it fails.
+func (c *Client) Watch(ctx context.Context, lastID int64, handle func(Event) error) error {
+	retry := DefaultWatchRetry
+	for {
+		var err error
+		if c.rpc != nil {
+			err = c.rpcWatch(ctx, &lastID, handle)
+		} else {
+			err = c.streamEvents(ctx, &lastID, &retry, handle)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+	}
+}
+
+func (c *Client) streamEvents(ctx context.Context, lastID *int64, retry *time.Duration, handle func(Event) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/todo/events", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(*lastID, 10))
+	}
+
+	r, err := c.stream.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, ErrConnection)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return newStatusError(r)
+	}
+
+	var data strings.Builder
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			if data.Len() == 0 {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(data.String()), &e); err != nil {
+				return fmt.Errorf("%w: Invalid event: %s", ErrInvalidResponse, err)
+			}
+			data.Reset()
+			if err := handle(e); err != nil {
+				return err
+			}
+		case "id":
+			if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+				*lastID = id
+			}
+		case "data":
+			data.WriteString(value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				*retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", err, ErrConnection)
+	}
+	return fmt.Errorf("stream closed: %w", ErrConnection)
+}
+
+func (c *Client) rpcWatch(ctx context.Context, lastID *int64, handle func(Event) error) error {
+	// no client timeout, the stream is expected to stay open
+	stream, err := todorpc.NewClient(c.baseURL, c.stream).Watch(ctx, &todorpc.WatchRequest{LastEventID: *lastID})
+	if err != nil {
+		return rpcError(err)
+	}
+	defer stream.Close()
+
+	for {
+		e, err := stream.Receive()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("stream closed: %w", ErrConnection)
+		}
+		if err != nil {
+			return rpcError(err)
+		}
+		*lastID = e.ID
+		if err := handle(Event(*e)); err != nil {
+			return err
+		}
+	}
+}
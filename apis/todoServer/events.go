@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	EventAdd      = "add"
+	EventComplete = "complete"
+	EventDelete   = "delete"
+	EventEdit     = "edit"
+)
+
+const (
+	eventHistory   = 100
+	eventBuffer    = 16
+	eventKeepAlive = 30 * time.Second
+)
+
+type event struct {
+	ID     int64     `json:"id"`
+	Type   string    `json:"type"`
+	ItemID int       `json:"item_id"`
+	Task   string    `json:"task"`
+	Date   time.Time `json:"date"`
+}
+
+// broker fans todo changes out to subscribers. It keeps a short history so
+// reconnecting clients can resume from the last event they have seen.
+type broker struct {
+	mu        sync.Mutex
+	lastID    int64
+	history   []event
+	subs      map[chan event]struct{}
+	listeners []func(event)
+}
+
+func newBroker() *broker {
+	return &broker{
+		subs: map[chan event]struct{}{},
+	}
+}
+
+// listen registers f to be called for every published event. Unlike
+// subscribers, listeners never miss an event and must not block.
+func (b *broker) listen(f func(event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, f)
+}
+
+func (b *broker) publish(typ string, itemID int, task string) {
+	b.mu.Lock()
+
+	b.lastID++
+	e := event{
+		ID:     b.lastID,
+		Type:   typ,
+		ItemID: itemID,
+		Task:   task,
+		Date:   time.Now(),
+	}
+	b.history = append(b.history, e)
+	if len(b.history) > eventHistory {
+		b.history = b.history[len(b.history)-eventHistory:]
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// slow subscriber, drop it and let it resume with Last-Event-ID
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	listeners := b.listeners
+	b.mu.Unlock()
+
+	for _, f := range listeners {
+		f(e)
+	}
+}
+
+// subscribe returns a channel of new events and, when resuming, the events
+// published after lastID. A new subscriber gets no history.
+func (b *broker) subscribe(lastID int64, resume bool) (chan event, []event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []event
+	for _, e := range b.history {
+		if resume && e.ID > lastID {
+			missed = append(missed, e)
+		}
+	}
+	ch := make(chan event, eventBuffer)
+	b.subs[ch] = struct{}{}
+	return ch, missed
+}
+
+func (b *broker) unsubscribe(ch chan event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+func eventsHandler(b *broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			replyError(w, r, http.StatusMethodNotAllowed, "method not supported")
+			return
+		}
+
+		var lastID int64
+		v := r.Header.Get("Last-Event-ID")
+		if v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				replyError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid Last-Event-ID: %s", v))
+				return
+			}
+			lastID = id
+		}
+
+		rc := http.NewResponseController(w)
+		// the stream outlives the server write timeout
+		rc.SetWriteDeadline(time.Time{})
+
+		ch, missed := b.subscribe(lastID, v != "")
+		defer b.unsubscribe(ch)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		for _, e := range missed {
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(eventKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
@@ -5,9 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"go-cmd-book/todo"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
 	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 var (
@@ -15,7 +20,7 @@ var (
 	ErrInvalidData = errors.New("invalid data")
 )
 
-func todoRouter(todoFile string, l sync.Locker) http.HandlerFunc {
+func todoRouter(todoFile string, l sync.Locker, events *broker, maxTaskLength int) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		list := &todo.List{}
 		l.Lock()
@@ -28,9 +33,9 @@ func todoRouter(todoFile string, l sync.Locker) http.HandlerFunc {
 		if r.URL.Path == "" {
 			switch r.Method {
 			case http.MethodGet:
-				getAllHandler(w, r, list)
+				getAllHandler(w, r, list, todoFile)
 			case http.MethodPost:
-				addHandler(w, r, list, todoFile)
+				addHandler(w, r, list, todoFile, events, maxTaskLength)
 			default:
 				message := "method not supported"
 				replyError(w, r, http.StatusMethodNotAllowed, message)
@@ -52,9 +57,9 @@ func todoRouter(todoFile string, l sync.Locker) http.HandlerFunc {
 		case http.MethodGet:
 			getOneHandler(w, r, list, id)
 		case http.MethodDelete:
-			deleteHandler(w, r, list, id, todoFile)
+			deleteHandler(w, r, list, id, todoFile, events)
 		case http.MethodPatch:
-			patchHandler(w, r, list, id, todoFile)
+			patchHandler(w, r, list, id, todoFile, events, maxTaskLength)
 		default:
 			message := "method not supported"
 			replyError(w, r, http.StatusMethodNotAllowed, message)
@@ -62,59 +67,130 @@ func todoRouter(todoFile string, l sync.Locker) http.HandlerFunc {
 	}
 }
 
-func getAllHandler(w http.ResponseWriter, r *http.Request, list *todo.List) {
+func getAllHandler(w http.ResponseWriter, r *http.Request, list *todo.List, todoFile string) {
+	offset, limit, err := parsePagination(r.URL.Query())
+	if err != nil {
+		replyError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	start := min(offset, len(*list))
+	end := len(*list)
+	if limit > 0 {
+		end = start + min(limit, end-start)
+	}
 	resp := &todoResponse{
-		Results: *list,
+		Results:      (*list)[start:end],
+		TotalResults: len(*list),
+	}
+
+	var modified time.Time
+	if fi, err := os.Stat(todoFile); err == nil {
+		modified = fi.ModTime()
+	}
+	if notModified(w, r, resp.ETag(), modified) {
+		return
 	}
 	replyJSONContent(w, r, http.StatusOK, resp)
 }
 
 func getOneHandler(w http.ResponseWriter, r *http.Request, list *todo.List, id int) {
 	resp := &todoResponse{
-		Results: (*list)[id-1 : id],
+		Results:      (*list)[id-1 : id],
+		TotalResults: 1,
+	}
+	if notModified(w, r, itemETag(list, id), itemModified(list, id)) {
+		return
 	}
 	replyJSONContent(w, r, http.StatusOK, resp)
 }
 
-func deleteHandler(w http.ResponseWriter, r *http.Request, list *todo.List, id int, todoFile string) {
+func deleteHandler(w http.ResponseWriter, r *http.Request, list *todo.List, id int, todoFile string, events *broker) {
+	if !preconditionMet(r, itemETag(list, id)) {
+		replyError(w, r, http.StatusPreconditionFailed, "item has been modified")
+		return
+	}
+	task := (*list)[id-1].Task
 	list.Delete(id)
 	if err := list.Save(todoFile); err != nil {
 		replyError(w, r, http.StatusInternalServerError, err.Error())
+		return
 	}
+	events.publish(EventDelete, id, task)
 	replyTextContent(w, r, http.StatusNoContent, "")
 }
 
-func patchHandler(w http.ResponseWriter, r *http.Request, list *todo.List, id int, todoFile string) {
-	q := r.URL.Query()
-	if _, ok := q["complete"]; !ok {
-		message := "Missing query param 'complete'"
-		replyError(w, r, http.StatusBadRequest, message)
+// patchHandler completes item id with the complete query param, or renames
+// it to the task of the body.
+func patchHandler(w http.ResponseWriter, r *http.Request, list *todo.List, id int, todoFile string, events *broker, maxTaskLength int) {
+	_, complete := r.URL.Query()["complete"]
+	task := ""
+	if !complete {
+		var ok bool
+		if task, ok = readTask(w, r, maxTaskLength); !ok {
+			return
+		}
+	}
+	if !preconditionMet(r, itemETag(list, id)) {
+		replyError(w, r, http.StatusPreconditionFailed, "item has been modified")
+		return
+	}
+	event := EventComplete
+	if complete {
+		list.Complete(id)
+	} else {
+		(*list)[id-1].Task = task
+		event = EventEdit
 	}
-	list.Complete(id)
 	if err := list.Save(todoFile); err != nil {
 		replyError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
+	events.publish(event, id, (*list)[id-1].Task)
 	replyTextContent(w, r, http.StatusNoContent, "")
 }
 
-func addHandler(w http.ResponseWriter, r *http.Request, list *todo.List, todoFile string) {
+func addHandler(w http.ResponseWriter, r *http.Request, list *todo.List, todoFile string, events *broker, maxTaskLength int) {
+	task, ok := readTask(w, r, maxTaskLength)
+	if !ok {
+		return
+	}
+	list.Add(task)
+	if err := list.Save(todoFile); err != nil {
+		replyError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	events.publish(EventAdd, len(*list), task)
+	replyTextContent(w, r, http.StatusCreated, "")
+}
+
+// readTask decodes the task of the request body. When it is invalid, it
+// replies with the error and returns false.
+func readTask(w http.ResponseWriter, r *http.Request, maxTaskLength int) (string, bool) {
 	item := struct {
 		Task string `json:"task"`
 	}{}
 
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-		message := fmt.Sprintf("Invalid JSON: %s", err)
-		replyError(w, r, http.StatusBadRequest, message)
-		return
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			message := fmt.Sprintf("Body larger than %d bytes", maxErr.Limit)
+			replyError(w, r, http.StatusRequestEntityTooLarge, message)
+		case errors.Is(err, io.EOF) && r.Method == http.MethodPatch:
+			replyError(w, r, http.StatusBadRequest, "Missing query param 'complete' or task")
+		default:
+			message := fmt.Sprintf("Invalid JSON: %s", err)
+			replyError(w, r, http.StatusBadRequest, message)
+		}
+		return "", false
 	}
 
-	list.Add(item.Task)
-	if err := list.Save(todoFile); err != nil {
-		replyError(w, r, http.StatusInternalServerError, err.Error())
-		return
+	if maxTaskLength > 0 && utf8.RuneCountInString(item.Task) > maxTaskLength {
+		message := fmt.Sprintf("%s: task longer than %d characters", ErrInvalidData, maxTaskLength)
+		replyError(w, r, http.StatusBadRequest, message)
+		return "", false
 	}
-	replyTextContent(w, r, http.StatusCreated, "")
+	return item.Task, true
 }
 
 func validateID(path string, list *todo.List) (int, error) {
@@ -123,12 +199,35 @@ func validateID(path string, list *todo.List) (int, error) {
 		return 0, fmt.Errorf("%w: Invalid ID: %s", ErrInvalidData, err)
 	}
 
+	return id, checkID(id, list)
+}
+
+func checkID(id int, list *todo.List) error {
 	if id < 1 {
-		return 0, fmt.Errorf("%w, Invalid ID: less than one", ErrInvalidData)
+		return fmt.Errorf("%w, Invalid ID: less than one", ErrInvalidData)
 	}
 
 	if id > len(*list) {
-		return id, fmt.Errorf("%w: ID %d not found ", ErrNotFound, id)
+		return fmt.Errorf("%w: ID %d not found ", ErrNotFound, id)
+	}
+	return nil
+}
+
+func parsePagination(q url.Values) (int, int, error) {
+	offset, limit := 0, 0
+	if v := q.Get("offset"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			return 0, 0, fmt.Errorf("%w: Invalid offset: %s", ErrInvalidData, v)
+		}
+		offset = o
+	}
+	if v := q.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 {
+			return 0, 0, fmt.Errorf("%w: Invalid limit: %s", ErrInvalidData, v)
+		}
+		limit = l
 	}
-	return id, nil
+	return offset, limit, nil
 }
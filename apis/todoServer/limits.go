@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const maxBuckets = 10000
+
+type limits struct {
+	Rate          float64 // requests per second per client, 0 disables limiting
+	Burst         int
+	MaxBodySize   int64
+	MaxTaskLength int
+	// PrivateWebhooks lets webhooks target loopback and private addresses.
+	PrivateWebhooks bool
+}
+
+var defaultLimits = limits{
+	Rate:          10,
+	Burst:         20,
+	MaxBodySize:   1 << 20,
+	MaxTaskLength: 1024,
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per client key, keeping at most
+// maxBuckets of them.
+type rateLimiter struct {
+	mu         sync.Mutex
+	rate       float64
+	burst      float64
+	buckets    map[string]*bucket
+	maxBuckets int
+	now        func() time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:       rate,
+		burst:      float64(max(burst, 1)),
+		buckets:    map[string]*bucket{},
+		maxBuckets: maxBuckets,
+		now:        time.Now,
+	}
+}
+
+// allow takes a token for key. When none is left it returns the time to
+// wait for the next one.
+func (rl *rateLimiter) allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	b, ok := rl.buckets[key]
+	if !ok {
+		if len(rl.buckets) >= rl.maxBuckets {
+			rl.evict(now)
+		}
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// evict drops buckets that have refilled completely, they carry no state.
+// When none has, it drops the least recently used one so the map stays
+// bounded.
+func (rl *rateLimiter) evict(now time.Time) {
+	oldest := ""
+	for k, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.buckets, k)
+			continue
+		}
+		if oldest == "" || b.last.Before(rl.buckets[oldest].last) {
+			oldest = k
+		}
+	}
+	if len(rl.buckets) >= rl.maxBuckets {
+		delete(rl.buckets, oldest)
+	}
+}
+
+// clientKey identifies the caller by remote IP. The Authorization header
+// is not used, the server does not check tokens and a client could send a
+// new one with every request.
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func limitMiddleware(next http.Handler, rl *rateLimiter, maxBodySize int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rl != nil {
+			if ok, wait := rl.allow(clientKey(r)); !ok {
+				retry := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(retry, 1)))
+				replyError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+		}
+		if maxBodySize > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
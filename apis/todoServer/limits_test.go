@@ -0,0 +1,128 @@
+package main
+
+import (
+	"go-cmd-book/apis/openapi"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rl := newRateLimiter(2, 3)
+	rl.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := rl.allow("a")
+		assert.True(t, ok, "burst request %d", i)
+	}
+	ok, wait := rl.allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// other clients have their own bucket
+	ok, _ = rl.allow("b")
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = rl.allow("a")
+	assert.True(t, ok)
+	ok, _ = rl.allow("a")
+	assert.False(t, ok)
+
+	// refill never exceeds the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ := rl.allow("a")
+		assert.True(t, ok)
+	}
+	ok, _ = rl.allow("a")
+	assert.False(t, ok)
+}
+
+func TestClientKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/todo", nil)
+	r.RemoteAddr = "10.0.0.1:5555"
+	assert.Equal(t, "ip:10.0.0.1", clientKey(r))
+
+	r.Header.Set("Authorization", "Bearer secret")
+	assert.Equal(t, "ip:10.0.0.1", clientKey(r))
+}
+
+func TestRateLimiterBound(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rl := newRateLimiter(1, 2)
+	rl.maxBuckets = 2
+	rl.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "a", "b", "b"} {
+		ok, _ := rl.allow(key)
+		assert.True(t, ok)
+		now = now.Add(time.Millisecond)
+	}
+	ok, _ := rl.allow("c")
+	assert.True(t, ok)
+	assert.Len(t, rl.buckets, 2)
+	// a was used least recently
+	assert.NotContains(t, rl.buckets, "a")
+	ok, _ = rl.allow("b")
+	assert.False(t, ok)
+}
+
+func TestLimits(t *testing.T) {
+	todoFile, err := os.CreateTemp("", "todotest")
+	assert.NoError(t, err)
+	defer os.Remove(todoFile.Name())
+
+	lim := limits{Rate: 1, Burst: 4, MaxBodySize: 64, MaxTaskLength: 10}
+	ts := httptest.NewServer(newMux(todoFile.Name(), lim))
+	defer ts.Close()
+
+	d, err := openapi.Load()
+	assert.NoError(t, err)
+
+	post := func(body, token string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/todo", strings.NewReader(body))
+		assert.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		t.Cleanup(func() { r.Body.Close() })
+		return r
+	}
+
+	t.Run("TaskLength", func(t *testing.T) {
+		r := post(`{"task":"this task is too long"}`, "length")
+		assert.Equal(t, http.StatusBadRequest, r.StatusCode)
+		checkResponse(t, d, r)
+	})
+
+	t.Run("BodySize", func(t *testing.T) {
+		r := post(`{"task":"`+strings.Repeat("a", 100)+`"}`, "body")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, r.StatusCode)
+		checkResponse(t, d, r)
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		// the two requests above took a token each
+		for i := 0; i < 2; i++ {
+			r := post(`{"task":"ok"}`, "rate")
+			assert.Equal(t, http.StatusCreated, r.StatusCode)
+		}
+		r := post(`{"task":"ok"}`, "rate")
+		assert.Equal(t, http.StatusTooManyRequests, r.StatusCode)
+		assert.Equal(t, "1", r.Header.Get("Retry-After"))
+		checkResponse(t, d, r)
+
+		// a new token does not get a new bucket
+		r = post(`{"task":"ok"}`, "other")
+		assert.Equal(t, http.StatusTooManyRequests, r.StatusCode)
+	})
+}
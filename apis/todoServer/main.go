@@ -11,18 +11,41 @@ import (
 func main() {
 	host := flag.String("h", "localhost", "host")
 	port := flag.Int("p", 8080, "port")
+	rpcPort := flag.Int("rpc-port", 8081, "port of the RPC API, 0 disables it")
 	todoFile := flag.String("f", "todoServer.json", "todo JSON file")
+	lim := defaultLimits
+	flag.Float64Var(&lim.Rate, "rate", lim.Rate, "requests per second allowed per client, 0 disables rate limiting")
+	flag.IntVar(&lim.Burst, "burst", lim.Burst, "requests a client can burst above the rate")
+	flag.Int64Var(&lim.MaxBodySize, "max-body", lim.MaxBodySize, "maximum request body size in bytes")
+	flag.IntVar(&lim.MaxTaskLength, "max-task", lim.MaxTaskLength, "maximum task length in characters")
+	flag.BoolVar(&lim.PrivateWebhooks, "webhooks-private", false, "allow webhooks to loopback and private addresses")
 
 	flag.Parse()
 
-	s := http.Server{
+	a := newAPI(*todoFile, lim)
+	servers := []*http.Server{{
 		Addr:         fmt.Sprintf("%s:%d", *host, *port),
-		Handler:      newMux(*todoFile),
+		Handler:      a.restHandler(),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
+	}}
+	if *rpcPort != 0 {
+		servers = append(servers, &http.Server{
+			Addr:         fmt.Sprintf("%s:%d", *host, *rpcPort),
+			Handler:      a.rpcHandler(),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		})
 	}
 
-	if err := s.ListenAndServe(); err != nil {
+	errCh := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s *http.Server) {
+			errCh <- s.ListenAndServe()
+		}(s)
+	}
+
+	if err := <-errCh; err != nil {
 		fmt.Fprint(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"go-cmd-book/apis/openapi"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkResponse validates a response against the operation documented for
+// the request and returns the templated "METHOD path" it matched.
+func checkResponse(t *testing.T, d *openapi.Document, r *http.Response) string {
+	t.Helper()
+	path, op, err := d.Operation(r.Request.Method, r.Request.URL.Path)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	doc, err := d.Response(op, r.StatusCode)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	for name := range doc.Headers {
+		assert.NotEmpty(t, r.Header.Get(name), "header %s", name)
+	}
+	schema, err := doc.Schema(r.Header.Get("Content-Type"))
+	if !assert.NoError(t, err) || schema == nil {
+		return r.Request.Method + " " + path
+	}
+
+	switch {
+	case strings.HasPrefix(r.Header.Get("Content-Type"), "application/json"):
+		var body any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.NoError(t, d.Validate(schema, body))
+	case strings.HasPrefix(r.Header.Get("Content-Type"), "text/event-stream"):
+		s := bufio.NewScanner(r.Body)
+		for s.Scan() {
+			data, ok := strings.CutPrefix(s.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var body any
+			assert.NoError(t, json.Unmarshal([]byte(data), &body))
+			assert.NoError(t, d.Validate(schema, body))
+			break
+		}
+	default:
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, d.Validate(schema, string(body)))
+	}
+	return r.Request.Method + " " + path
+}
+
+func TestOpenAPI(t *testing.T) {
+	url, cleanup := setupApi(t)
+	defer cleanup()
+
+	d, err := openapi.Load()
+	assert.NoError(t, err)
+
+	r, err := http.Get(url + "/openapi.json")
+	assert.NoError(t, err)
+	defer r.Body.Close()
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	body, err := io.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(openapi.Spec), string(body))
+
+	etag := func(path string) string {
+		r, err := http.Get(url + path)
+		assert.NoError(t, err)
+		r.Body.Close()
+		return r.Header.Get("ETag")
+	}
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		body   string
+		status int
+	}{
+		{"Root", http.MethodGet, "/", nil, "", http.StatusOK},
+		{"Asset", http.MethodGet, "/app.js", nil, "", http.StatusOK},
+		{"AssetNotFound", http.MethodGet, "/missing.js", nil, "", http.StatusNotFound},
+		{"Spec", http.MethodGet, "/openapi.json", nil, "", http.StatusOK},
+		{"List", http.MethodGet, "/todo", nil, "", http.StatusOK},
+		{"ListPage", http.MethodGet, "/todo?limit=1&offset=1", nil, "", http.StatusOK},
+		{"ListNotModified", http.MethodGet, "/todo", map[string]string{"If-None-Match": etag("/todo")}, "", http.StatusNotModified},
+		{"ListBadLimit", http.MethodGet, "/todo?limit=x", nil, "", http.StatusBadRequest},
+		{"Add", http.MethodPost, "/todo", nil, `{"task":"Task number 3"}`, http.StatusCreated},
+		{"AddInvalid", http.MethodPost, "/todo", nil, `{`, http.StatusBadRequest},
+		{"Get", http.MethodGet, "/todo/1", nil, "", http.StatusOK},
+		{"GetNotModified", http.MethodGet, "/todo/1", map[string]string{"If-None-Match": etag("/todo/1")}, "", http.StatusNotModified},
+		{"GetInvalid", http.MethodGet, "/todo/x", nil, "", http.StatusBadRequest},
+		{"GetNotFound", http.MethodGet, "/todo/99", nil, "", http.StatusNotFound},
+		{"CompleteMissingParam", http.MethodPatch, "/todo/1", nil, "", http.StatusBadRequest},
+		{"Edit", http.MethodPatch, "/todo/2", nil, `{"task":"Task number 2"}`, http.StatusNoContent},
+		{"CompleteStale", http.MethodPatch, "/todo/1?complete", map[string]string{"If-Match": `"stale"`}, "", http.StatusPreconditionFailed},
+		{"Complete", http.MethodPatch, "/todo/1?complete", nil, "", http.StatusNoContent},
+		{"CompleteNotFound", http.MethodPatch, "/todo/99?complete", nil, "", http.StatusNotFound},
+		{"CompleteInvalid", http.MethodPatch, "/todo/x?complete", nil, "", http.StatusBadRequest},
+		{"DeleteStale", http.MethodDelete, "/todo/1", map[string]string{"If-Match": `"stale"`}, "", http.StatusPreconditionFailed},
+		{"Delete", http.MethodDelete, "/todo/1", nil, "", http.StatusNoContent},
+		{"DeleteNotFound", http.MethodDelete, "/todo/99", nil, "", http.StatusNotFound},
+		{"DeleteInvalid", http.MethodDelete, "/todo/x", nil, "", http.StatusBadRequest},
+		{"Events", http.MethodGet, "/todo/events", map[string]string{"Last-Event-ID": "0"}, "", http.StatusOK},
+		{"EventsInvalid", http.MethodGet, "/todo/events", map[string]string{"Last-Event-ID": "x"}, "", http.StatusBadRequest},
+		{"AddWebhook", http.MethodPost, "/webhooks", nil, `{"url":"http://localhost/hook","events":["add"]}`, http.StatusCreated},
+		{"AddWebhookInvalid", http.MethodPost, "/webhooks", nil, `{"url":"ftp://localhost"}`, http.StatusBadRequest},
+		{"ListWebhooks", http.MethodGet, "/webhooks", nil, "", http.StatusOK},
+		{"GetWebhook", http.MethodGet, "/webhooks/1", nil, "", http.StatusOK},
+		{"GetWebhookNotFound", http.MethodGet, "/webhooks/9", nil, "", http.StatusNotFound},
+		{"WebhookFailures", http.MethodGet, "/webhooks/1/failures", nil, "", http.StatusOK},
+		{"DeleteWebhookInvalid", http.MethodDelete, "/webhooks/x", nil, "", http.StatusBadRequest},
+		{"DeleteWebhook", http.MethodDelete, "/webhooks/1", nil, "", http.StatusNoContent},
+	}
+
+	covered := map[string]bool{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, url+tc.path, strings.NewReader(tc.body))
+			assert.NoError(t, err)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			r, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer r.Body.Close()
+
+			assert.Equal(t, tc.status, r.StatusCode)
+			covered[checkResponse(t, d, r)] = true
+		})
+	}
+
+	for _, op := range d.Operations() {
+		assert.True(t, covered[op], "operation %s not exercised", op)
+	}
+}
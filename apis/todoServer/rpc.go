@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-cmd-book/apis/todorpc"
+	"go-cmd-book/todo"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+func (a *api) rpcHandler() http.Handler {
+	m := http.NewServeMux()
+	m.Handle(todorpc.AddProcedure, unary(a.rpcAdd))
+	m.Handle(todorpc.ListProcedure, unary(a.rpcList))
+	m.Handle(todorpc.GetProcedure, unary(a.rpcGet))
+	m.Handle(todorpc.CompleteProcedure, unary(a.rpcComplete))
+	m.Handle(todorpc.EditProcedure, unary(a.rpcEdit))
+	m.Handle(todorpc.DeleteProcedure, unary(a.rpcDelete))
+	m.HandleFunc(todorpc.WatchProcedure, a.rpcWatch)
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		replyRPCError(w, r, rpcError(todorpc.CodeUnimplemented, "%s", r.URL.Path))
+	})
+	return limitMiddleware(m, a.limiter, a.lim.MaxBodySize)
+}
+
+func rpcError(code todorpc.Code, format string, args ...any) *todorpc.Error {
+	return &todorpc.Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// listError maps the errors of the REST helpers to RPC codes.
+func listError(err error) *todorpc.Error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return rpcError(todorpc.CodeNotFound, "%s", err)
+	case errors.Is(err, ErrInvalidData):
+		return rpcError(todorpc.CodeInvalidArgument, "%s", err)
+	}
+	return rpcError(todorpc.CodeInternal, "%s", err)
+}
+
+func replyRPCError(w http.ResponseWriter, r *http.Request, err error) {
+	e := &todorpc.Error{}
+	if !errors.As(err, &e) {
+		e = rpcError(todorpc.CodeInternal, "%s", err)
+	}
+	log.Printf("%s %s: Error %s %s", r.URL, r.Method, e.Code, e.Message)
+	body, _ := json.Marshal(e)
+	w.Header().Set("Content-Type", todorpc.ContentTypeUnary)
+	w.WriteHeader(e.Code.HTTPStatus())
+	w.Write(body)
+}
+
+func checkContentType(r *http.Request, want string) *todorpc.Error {
+	if r.Method != http.MethodPost {
+		return rpcError(todorpc.CodeUnimplemented, "method %s not supported", r.Method)
+	}
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mt != want {
+		return rpcError(todorpc.CodeInvalidArgument, "content type must be %s", want)
+	}
+	return nil
+}
+
+func decodeRPC(data []byte, req any) *todorpc.Error {
+	if err := json.Unmarshal(data, req); err != nil {
+		return rpcError(todorpc.CodeInvalidArgument, "Invalid JSON: %s", err)
+	}
+	return nil
+}
+
+func unary[Req, Resp any](call func(*Req) (*Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, todorpc.ContentTypeUnary); err != nil {
+			replyRPCError(w, r, err)
+			return
+		}
+		req := new(Req)
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				replyRPCError(w, r, rpcError(todorpc.CodeResourceExhausted, "Body larger than %d bytes", maxErr.Limit))
+				return
+			}
+			replyRPCError(w, r, rpcError(todorpc.CodeInvalidArgument, "Invalid JSON: %s", err))
+			return
+		}
+
+		resp, err := call(req)
+		if err != nil {
+			replyRPCError(w, r, err)
+			return
+		}
+		body, err := json.Marshal(resp)
+		if err != nil {
+			replyRPCError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", todorpc.ContentTypeUnary)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}
+}
+
+func rpcItem(list *todo.List, id int) todorpc.Item {
+	i := (*list)[id-1]
+	return todorpc.Item{
+		ID:          id,
+		Task:        i.Task,
+		Done:        i.Done,
+		CreatedAt:   i.CreatedAt,
+		CompletedAt: i.CompletedAt,
+		ETag:        itemETag(list, id),
+	}
+}
+
+// withList loads the list under the shared lock and passes it to f.
+func (a *api) withList(f func(list *todo.List) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := &todo.List{}
+	if err := list.Get(a.todoFile); err != nil {
+		return rpcError(todorpc.CodeInternal, "%s", err)
+	}
+	return f(list)
+}
+
+func (a *api) save(list *todo.List) error {
+	if err := list.Save(a.todoFile); err != nil {
+		return rpcError(todorpc.CodeInternal, "%s", err)
+	}
+	return nil
+}
+
+func (a *api) rpcAdd(req *todorpc.AddRequest) (*todorpc.AddResponse, error) {
+	if a.lim.MaxTaskLength > 0 && utf8.RuneCountInString(req.Task) > a.lim.MaxTaskLength {
+		return nil, rpcError(todorpc.CodeInvalidArgument, "%s: task longer than %d characters", ErrInvalidData, a.lim.MaxTaskLength)
+	}
+	resp := &todorpc.AddResponse{}
+	return resp, a.withList(func(list *todo.List) error {
+		list.Add(req.Task)
+		if err := a.save(list); err != nil {
+			return err
+		}
+		a.events.publish(EventAdd, len(*list), req.Task)
+		resp.Item = rpcItem(list, len(*list))
+		return nil
+	})
+}
+
+func (a *api) rpcList(req *todorpc.ListRequest) (*todorpc.ListResponse, error) {
+	if req.Offset < 0 || req.Limit < 0 {
+		return nil, rpcError(todorpc.CodeInvalidArgument, "%s: offset and limit must not be negative", ErrInvalidData)
+	}
+	resp := &todorpc.ListResponse{Items: []todorpc.Item{}}
+	return resp, a.withList(func(list *todo.List) error {
+		start := min(req.Offset, len(*list))
+		end := len(*list)
+		if req.Limit > 0 {
+			end = start + min(req.Limit, end-start)
+		}
+		for id := start + 1; id <= end; id++ {
+			resp.Items = append(resp.Items, rpcItem(list, id))
+		}
+		resp.TotalResults = len(*list)
+		return nil
+	})
+}
+
+func (a *api) rpcGet(req *todorpc.GetRequest) (*todorpc.GetResponse, error) {
+	resp := &todorpc.GetResponse{}
+	return resp, a.withList(func(list *todo.List) error {
+		if err := checkID(req.ID, list); err != nil {
+			return listError(err)
+		}
+		resp.Item = rpcItem(list, req.ID)
+		return nil
+	})
+}
+
+func (a *api) rpcComplete(req *todorpc.CompleteRequest) (*todorpc.CompleteResponse, error) {
+	resp := &todorpc.CompleteResponse{}
+	return resp, a.withList(func(list *todo.List) error {
+		if err := checkID(req.ID, list); err != nil {
+			return listError(err)
+		}
+		if req.IfMatch != "" && !etagMatch(req.IfMatch, itemETag(list, req.ID)) {
+			return rpcError(todorpc.CodeFailedPrecondition, "item has been modified")
+		}
+		list.Complete(req.ID)
+		if err := a.save(list); err != nil {
+			return err
+		}
+		a.events.publish(EventComplete, req.ID, (*list)[req.ID-1].Task)
+		resp.Item = rpcItem(list, req.ID)
+		return nil
+	})
+}
+
+func (a *api) rpcEdit(req *todorpc.EditRequest) (*todorpc.EditResponse, error) {
+	if a.lim.MaxTaskLength > 0 && utf8.RuneCountInString(req.Task) > a.lim.MaxTaskLength {
+		return nil, rpcError(todorpc.CodeInvalidArgument, "%s: task longer than %d characters", ErrInvalidData, a.lim.MaxTaskLength)
+	}
+	resp := &todorpc.EditResponse{}
+	return resp, a.withList(func(list *todo.List) error {
+		if err := checkID(req.ID, list); err != nil {
+			return listError(err)
+		}
+		if req.IfMatch != "" && !etagMatch(req.IfMatch, itemETag(list, req.ID)) {
+			return rpcError(todorpc.CodeFailedPrecondition, "item has been modified")
+		}
+		(*list)[req.ID-1].Task = req.Task
+		if err := a.save(list); err != nil {
+			return err
+		}
+		a.events.publish(EventEdit, req.ID, req.Task)
+		resp.Item = rpcItem(list, req.ID)
+		return nil
+	})
+}
+
+func (a *api) rpcDelete(req *todorpc.DeleteRequest) (*todorpc.DeleteResponse, error) {
+	return &todorpc.DeleteResponse{}, a.withList(func(list *todo.List) error {
+		if err := checkID(req.ID, list); err != nil {
+			return listError(err)
+		}
+		if req.IfMatch != "" && !etagMatch(req.IfMatch, itemETag(list, req.ID)) {
+			return rpcError(todorpc.CodeFailedPrecondition, "item has been modified")
+		}
+		task := (*list)[req.ID-1].Task
+		list.Delete(req.ID)
+		if err := a.save(list); err != nil {
+			return err
+		}
+		a.events.publish(EventDelete, req.ID, task)
+		return nil
+	})
+}
+
+// rpcWatch streams events until the client goes away. When the subscriber
+// falls behind, the stream ends with CodeUnavailable and the client resumes
+// from the last event it received.
+func (a *api) rpcWatch(w http.ResponseWriter, r *http.Request) {
+	if err := checkContentType(r, todorpc.ContentTypeStream); err != nil {
+		replyRPCError(w, r, err)
+		return
+	}
+	_, msg, err := todorpc.ReadEnvelope(r.Body)
+	if err != nil {
+		replyRPCError(w, r, rpcError(todorpc.CodeInvalidArgument, "Invalid envelope: %s", err))
+		return
+	}
+	req := &todorpc.WatchRequest{}
+	if err := decodeRPC(msg, req); err != nil {
+		replyRPCError(w, r, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	ch, missed := a.events.subscribe(req.LastEventID, req.LastEventID > 0)
+	defer a.events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", todorpc.ContentTypeStream)
+	w.WriteHeader(http.StatusOK)
+	send := func(e event) bool {
+		body, err := json.Marshal(todorpc.Event(e))
+		if err != nil {
+			return false
+		}
+		return todorpc.WriteEnvelope(w, false, body) == nil && rc.Flush() == nil
+	}
+	for _, e := range missed {
+		if !send(e) {
+			return
+		}
+	}
+	rc.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				trailer, _ := json.Marshal(map[string]any{
+					"error": rpcError(todorpc.CodeUnavailable, "subscriber too slow"),
+				})
+				todorpc.WriteEnvelope(w, true, trailer)
+				rc.Flush()
+				return
+			}
+			if !send(e) {
+				return
+			}
+		}
+	}
+}
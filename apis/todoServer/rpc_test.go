@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"go-cmd-book/apis/todorpc"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupRPC serves the REST and RPC front ends of one api.
+func setupRPC(t *testing.T) (string, *todorpc.Client, func()) {
+	t.Helper()
+	todoFile, err := os.CreateTemp("", "todotest")
+	assert.NoError(t, err)
+
+	a := newAPI(todoFile.Name(), limits{MaxTaskLength: 20})
+	rest := httptest.NewServer(a.restHandler())
+	rpc := httptest.NewServer(a.rpcHandler())
+	return rest.URL, todorpc.NewClient(rpc.URL, nil), func() {
+		rest.Close()
+		rpc.Close()
+		os.Remove(todoFile.Name())
+	}
+}
+
+func TestRPC(t *testing.T) {
+	restURL, c, cleanup := setupRPC(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("Add", func(t *testing.T) {
+		resp, err := c.Add(ctx, &todorpc.AddRequest{Task: "Task number 1"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resp.Item.ID)
+		assert.Equal(t, "Task number 1", resp.Item.Task)
+		assert.NotEmpty(t, resp.Item.ETag)
+
+		_, err = c.Add(ctx, &todorpc.AddRequest{Task: strings.Repeat("a", 21)})
+		assert.Equal(t, todorpc.CodeInvalidArgument, todorpc.CodeOf(err))
+	})
+
+	t.Run("SharedWithREST", func(t *testing.T) {
+		r, err := http.Post(restURL+"/todo", "application/json", strings.NewReader(`{"task":"Task number 2"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, r.StatusCode)
+
+		resp, err := c.List(ctx, &todorpc.ListRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.TotalResults)
+		assert.Len(t, resp.Items, 2)
+		assert.Equal(t, "Task number 2", resp.Items[1].Task)
+
+		r, err = http.Get(restURL + "/todo/1")
+		assert.NoError(t, err)
+		r.Body.Close()
+		assert.Equal(t, r.Header.Get("ETag"), resp.Items[0].ETag)
+	})
+
+	t.Run("ListPage", func(t *testing.T) {
+		resp, err := c.List(ctx, &todorpc.ListRequest{Offset: 1, Limit: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.TotalResults)
+		assert.Len(t, resp.Items, 1)
+		assert.Equal(t, 2, resp.Items[0].ID)
+	})
+
+	t.Run("ListHugeLimit", func(t *testing.T) {
+		resp, err := c.List(ctx, &todorpc.ListRequest{Offset: 1, Limit: math.MaxInt})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Items, 1)
+		assert.Equal(t, 2, resp.Items[0].ID)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		resp, err := c.Get(ctx, &todorpc.GetRequest{ID: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, "Task number 2", resp.Item.Task)
+
+		_, err = c.Get(ctx, &todorpc.GetRequest{ID: 3})
+		assert.Equal(t, todorpc.CodeNotFound, todorpc.CodeOf(err))
+		_, err = c.Get(ctx, &todorpc.GetRequest{ID: 0})
+		assert.Equal(t, todorpc.CodeInvalidArgument, todorpc.CodeOf(err))
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		item, err := c.Get(ctx, &todorpc.GetRequest{ID: 1})
+		assert.NoError(t, err)
+
+		resp, err := c.Complete(ctx, &todorpc.CompleteRequest{ID: 1, IfMatch: item.Item.ETag})
+		assert.NoError(t, err)
+		assert.True(t, resp.Item.Done)
+
+		_, err = c.Complete(ctx, &todorpc.CompleteRequest{ID: 1, IfMatch: item.Item.ETag})
+		assert.Equal(t, todorpc.CodeFailedPrecondition, todorpc.CodeOf(err))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := c.Delete(ctx, &todorpc.DeleteRequest{ID: 1, IfMatch: `"stale"`})
+		assert.Equal(t, todorpc.CodeFailedPrecondition, todorpc.CodeOf(err))
+
+		_, err = c.Delete(ctx, &todorpc.DeleteRequest{ID: 1})
+		assert.NoError(t, err)
+
+		resp, err := c.List(ctx, &todorpc.ListRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resp.TotalResults)
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		// resume after the first add
+		stream, err := c.Watch(ctx, &todorpc.WatchRequest{LastEventID: 1})
+		assert.NoError(t, err)
+		defer stream.Close()
+
+		types := []string{}
+		for i := 0; i < 3; i++ {
+			e, err := stream.Receive()
+			assert.NoError(t, err)
+			types = append(types, e.Type)
+		}
+		assert.Equal(t, []string{"add", "complete", "delete"}, types)
+
+		req, err := http.NewRequest(http.MethodPatch, restURL+"/todo/1?complete", nil)
+		assert.NoError(t, err)
+		_, err = http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		e, err := stream.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), e.ID)
+		assert.Equal(t, "complete", e.Type)
+		assert.Equal(t, "Task number 2", e.Task)
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		item, err := c.Get(ctx, &todorpc.GetRequest{ID: 1})
+		assert.NoError(t, err)
+
+		resp, err := c.Edit(ctx, &todorpc.EditRequest{ID: 1, Task: "Renamed", IfMatch: item.Item.ETag})
+		assert.NoError(t, err)
+		assert.Equal(t, "Renamed", resp.Item.Task)
+		assert.True(t, resp.Item.Done)
+
+		_, err = c.Edit(ctx, &todorpc.EditRequest{ID: 1, Task: "Again", IfMatch: item.Item.ETag})
+		assert.Equal(t, todorpc.CodeFailedPrecondition, todorpc.CodeOf(err))
+		_, err = c.Edit(ctx, &todorpc.EditRequest{ID: 9, Task: "Again"})
+		assert.Equal(t, todorpc.CodeNotFound, todorpc.CodeOf(err))
+	})
+}
+
+func TestRPCProtocol(t *testing.T) {
+	_, c, cleanup := setupRPC(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := c.Get(ctx, &todorpc.GetRequest{ID: 1})
+	assert.Equal(t, todorpc.CodeNotFound, todorpc.CodeOf(err))
+
+	a := newAPI(os.DevNull, limits{})
+	ts := httptest.NewServer(a.rpcHandler())
+	defer ts.Close()
+
+	t.Run("UnknownMethod", func(t *testing.T) {
+		r, err := http.Post(ts.URL+"/todo.v1.TodoService/Rename", todorpc.ContentTypeUnary, strings.NewReader("{}"))
+		assert.NoError(t, err)
+		defer r.Body.Close()
+		assert.Equal(t, http.StatusNotFound, r.StatusCode)
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Contains(t, string(body), `"code":"unimplemented"`)
+	})
+
+	t.Run("WrongContentType", func(t *testing.T) {
+		r, err := http.Post(ts.URL+todorpc.ListProcedure, "text/plain", strings.NewReader("{}"))
+		assert.NoError(t, err)
+		defer r.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		r, err := http.Post(ts.URL+todorpc.ListProcedure, todorpc.ContentTypeUnary, strings.NewReader("{"))
+		assert.NoError(t, err)
+		defer r.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+}
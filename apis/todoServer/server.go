@@ -1,32 +1,71 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"go-cmd-book/apis/openapi"
 	"go-cmd-book/todo"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
 
-func newMux(todoFile string) http.Handler {
-	mu := sync.Mutex{}
-	t := todoRouter(todoFile, &mu)
+// api holds the state shared by the REST and RPC front ends, so both
+// serialize on the same lock and publish to the same subscribers.
+type api struct {
+	todoFile string
+	mu       sync.Mutex
+	events   *broker
+	webhooks *webhooks
+	limiter  *rateLimiter
+	lim      limits
+}
+
+func newAPI(todoFile string, lim limits) *api {
+	a := &api{
+		todoFile: todoFile,
+		events:   newBroker(),
+		webhooks: newWebhooks(webhooksFile(todoFile)),
+		lim:      lim,
+	}
+	a.webhooks.allowPrivate = lim.PrivateWebhooks
+	if lim.Rate > 0 {
+		a.limiter = newRateLimiter(lim.Rate, lim.Burst)
+	}
+	a.events.listen(a.webhooks.notify)
+	return a
+}
+
+func newMux(todoFile string, lim limits) http.Handler {
+	return newAPI(todoFile, lim).restHandler()
+}
+
+func (a *api) restHandler() http.Handler {
+	t := todoRouter(a.todoFile, &a.mu, a.events, a.lim.MaxTaskLength)
+	hooks := webhooksRouter(a.webhooks)
 
 	m := http.NewServeMux()
-	m.HandleFunc("/", rootHandler)
+	m.Handle("/", uiHandler())
+	m.HandleFunc("/openapi.json", specHandler)
+	m.Handle("/todo/events", eventsHandler(a.events))
 	m.Handle("/todo", http.StripPrefix("/todo", t))
 	m.Handle("/todo/", http.StripPrefix("/todo/", t))
-	return m
+	m.Handle("/webhooks", http.StripPrefix("/webhooks", hooks))
+	m.Handle("/webhooks/", http.StripPrefix("/webhooks/", hooks))
+	return limitMiddleware(m, a.limiter, a.lim.MaxBodySize)
 }
 
-func rootHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		replyError(w, r, http.StatusNotFound, "")
+func specHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		replyError(w, r, http.StatusMethodNotAllowed, "method not supported")
 		return
 	}
-	content := "Hello, from the the api"
-	replyTextContent(w, r, http.StatusOK, content)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.Spec)
 }
 
 func replyTextContent(w http.ResponseWriter, r *http.Request, status int, content string) {
@@ -40,7 +79,7 @@ func replyError(w http.ResponseWriter, r *http.Request, status int, message stri
 	http.Error(w, http.StatusText(status), status)
 }
 
-func replyJSONContent(w http.ResponseWriter, r *http.Request, status int, resp *todoResponse) {
+func replyJSONContent(w http.ResponseWriter, r *http.Request, status int, resp any) {
 	body, err := json.Marshal(resp)
 	if err != nil {
 		replyError(w, r, http.StatusInternalServerError, err.Error())
@@ -53,7 +92,16 @@ func replyJSONContent(w http.ResponseWriter, r *http.Request, status int, resp *
 }
 
 type todoResponse struct {
-	Results todo.List `json:"results"`
+	Results      todo.List `json:"results"`
+	TotalResults int       `json:"total_results"`
+}
+
+// ETag identifies the representation of the response, ignoring the date.
+func (r *todoResponse) ETag() string {
+	return etag(struct {
+		Results      todo.List
+		TotalResults int
+	}{r.Results, r.TotalResults})
 }
 
 func (r *todoResponse) MarshalJSON() ([]byte, error) {
@@ -64,7 +112,66 @@ func (r *todoResponse) MarshalJSON() ([]byte, error) {
 	}{
 		Results:      r.Results,
 		Date:         time.Now().Unix(),
-		TotalResults: len(r.Results),
+		TotalResults: r.TotalResults,
 	}
 	return json.Marshal(resp)
 }
+
+func etag(v any) string {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func itemETag(list *todo.List, id int) string {
+	return etag((*list)[id-1])
+}
+
+func itemModified(list *todo.List, id int) time.Time {
+	i := (*list)[id-1]
+	if i.CompletedAt.After(i.CreatedAt) {
+		return i.CompletedAt
+	}
+	return i.CreatedAt
+}
+
+// etagMatch reports whether tag is listed in an If-Match or If-None-Match header.
+func etagMatch(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the caching headers and replies with 304 when the client
+// already holds the current representation.
+func notModified(w http.ResponseWriter, r *http.Request, tag string, modified time.Time) bool {
+	w.Header().Set("ETag", tag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatch(inm, tag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(ims) {
+			return false
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func preconditionMet(r *http.Request, tag string) bool {
+	im := r.Header.Get("If-Match")
+	return im == "" || etagMatch(im, tag)
+}
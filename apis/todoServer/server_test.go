@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,7 +22,8 @@ func setupApi(t *testing.T) (string, func()) {
 	tempTodoFile, err := os.CreateTemp("", "todotest")
 	assert.NoError(t, err)
 
-	ts := httptest.NewServer(newMux(tempTodoFile.Name()))
+	// the webhook receivers of the tests are local
+	ts := httptest.NewServer(newMux(tempTodoFile.Name(), limits{PrivateWebhooks: true}))
 
 	for i := 1; i < 3; i++ {
 		var body bytes.Buffer
@@ -39,6 +42,7 @@ func setupApi(t *testing.T) (string, func()) {
 	}
 	return ts.URL, func() {
 		ts.Close()
+		os.Remove(webhooksFile(tempTodoFile.Name()))
 	}
 }
 
@@ -61,11 +65,11 @@ func TestGet(t *testing.T) {
 	t.Run("GetRoot", func(t *testing.T) {
 		r, err := http.Get(url + "/")
 		assert.NoError(t, err)
-		assert.Equal(t, "text/plain", r.Header.Get("Content-Type"))
+		assert.Equal(t, "text/html; charset=utf-8", r.Header.Get("Content-Type"))
 
 		body, err := io.ReadAll(r.Body)
 		assert.NoError(t, err)
-		assert.Equal(t, "Hello, from the the api", string(body))
+		assert.Contains(t, string(body), "<title>Todo</title>")
 
 		defer r.Body.Close()
 		assert.Equal(t, 200, r.StatusCode)
@@ -198,3 +202,222 @@ func TestComplete(t *testing.T) {
 		assert.Equal(t, true, resp.Results[0].Done)
 	})
 }
+
+func TestEdit(t *testing.T) {
+	url, cleanup := setupApi(t)
+	defer cleanup()
+
+	patch := func(id, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPatch, url+"/todo/"+id, strings.NewReader(body))
+		assert.NoError(t, err)
+		r, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		r.Body.Close()
+		return r
+	}
+
+	r := patch("2", `{"task":"Renamed"}`)
+	assert.Equal(t, http.StatusNoContent, r.StatusCode)
+
+	var resp todoResponse
+	r, err := http.Get(url + "/todo")
+	assert.NoError(t, err)
+	defer r.Body.Close()
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&resp))
+	assert.Equal(t, 2, resp.TotalResults)
+	assert.Equal(t, "Task number 1", resp.Results[0].Task)
+	assert.Equal(t, "Renamed", resp.Results[1].Task)
+
+	assert.Equal(t, http.StatusBadRequest, patch("2", "").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, patch("2", "{").StatusCode)
+	assert.Equal(t, http.StatusNotFound, patch("9", `{"task":"Renamed"}`).StatusCode)
+}
+
+func TestPagination(t *testing.T) {
+	url, cleanup := setupApi(t)
+	defer cleanup()
+
+	t.Run("Limit", func(t *testing.T) {
+		r, err := http.Get(url + "/todo?limit=1")
+		assert.NoError(t, err)
+		defer r.Body.Close()
+		assert.Equal(t, http.StatusOK, r.StatusCode)
+
+		err = json.NewDecoder(r.Body).Decode(&resp)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.TotalResults)
+		assert.Len(t, resp.Results, 1)
+		assert.Equal(t, "Task number 1", resp.Results[0].Task)
+	})
+
+	t.Run("Offset", func(t *testing.T) {
+		r, err := http.Get(url + "/todo?limit=1&offset=1")
+		assert.NoError(t, err)
+		defer r.Body.Close()
+
+		err = json.NewDecoder(r.Body).Decode(&resp)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.TotalResults)
+		assert.Len(t, resp.Results, 1)
+		assert.Equal(t, "Task number 2", resp.Results[0].Task)
+	})
+
+	t.Run("OffsetPastEnd", func(t *testing.T) {
+		r, err := http.Get(url + "/todo?offset=5")
+		assert.NoError(t, err)
+		defer r.Body.Close()
+
+		err = json.NewDecoder(r.Body).Decode(&resp)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.TotalResults)
+		assert.Len(t, resp.Results, 0)
+	})
+
+	t.Run("HugeLimit", func(t *testing.T) {
+		r, err := http.Get(url + "/todo?limit=9223372036854775807&offset=1")
+		assert.NoError(t, err)
+		defer r.Body.Close()
+		assert.Equal(t, http.StatusOK, r.StatusCode)
+
+		err = json.NewDecoder(r.Body).Decode(&resp)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Results, 1)
+		assert.Equal(t, "Task number 2", resp.Results[0].Task)
+	})
+
+	t.Run("InvalidLimit", func(t *testing.T) {
+		r, err := http.Get(url + "/todo?limit=-1")
+		assert.NoError(t, err)
+		defer r.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+}
+
+func TestConditional(t *testing.T) {
+	url, cleanup := setupApi(t)
+	defer cleanup()
+
+	get := func(path, etag string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		assert.NoError(t, err)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		r, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		r.Body.Close()
+		return r
+	}
+
+	t.Run("NotModified", func(t *testing.T) {
+		r := get("/todo", "")
+		etag := r.Header.Get("ETag")
+		assert.NotEmpty(t, etag)
+		assert.NotEmpty(t, r.Header.Get("Last-Modified"))
+
+		r = get("/todo", etag)
+		assert.Equal(t, http.StatusNotModified, r.StatusCode)
+
+		r = get("/todo/1", etag)
+		assert.Equal(t, http.StatusOK, r.StatusCode)
+	})
+
+	t.Run("StaleComplete", func(t *testing.T) {
+		etag := get("/todo/1", "").Header.Get("ETag")
+
+		req, err := http.NewRequest(http.MethodPatch, url+"/todo/1?complete", nil)
+		assert.NoError(t, err)
+		req.Header.Set("If-Match", etag)
+		r, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, r.StatusCode)
+
+		req, err = http.NewRequest(http.MethodDelete, url+"/todo/1", nil)
+		assert.NoError(t, err)
+		req.Header.Set("If-Match", etag)
+		r, err = http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, r.StatusCode)
+
+		assert.NotEqual(t, etag, get("/todo/1", "").Header.Get("ETag"))
+	})
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		assert.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return fields
+		}
+		k, v, _ := strings.Cut(line, ": ")
+		fields[k] = v
+	}
+}
+
+func TestEvents(t *testing.T) {
+	url, cleanup := setupApi(t)
+	defer cleanup()
+
+	r, err := http.Get(url + "/todo/events")
+	assert.NoError(t, err)
+	defer r.Body.Close()
+	assert.Equal(t, "text/event-stream", r.Header.Get("Content-Type"))
+	stream := bufio.NewReader(r.Body)
+
+	// the two items added by the setup are not replayed
+	req, err := http.NewRequest(http.MethodPatch, url+"/todo/1?complete", nil)
+	assert.NoError(t, err)
+	_, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+
+	e := readEvent(t, stream)
+	assert.Equal(t, "3", e["id"])
+	assert.Equal(t, "complete", e["event"])
+	assert.Contains(t, e["data"], `"item_id":1`)
+	assert.Contains(t, e["data"], `"task":"Task number 1"`)
+
+	req, err = http.NewRequest(http.MethodDelete, url+"/todo/2", nil)
+	assert.NoError(t, err)
+	_, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "delete", readEvent(t, stream)["event"])
+
+	req, err = http.NewRequest(http.MethodPatch, url+"/todo/1", strings.NewReader(`{"task":"Renamed"}`))
+	assert.NoError(t, err)
+	_, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	e = readEvent(t, stream)
+	assert.Equal(t, "edit", e["event"])
+	assert.Contains(t, e["data"], `"task":"Renamed"`)
+
+	t.Run("Resume", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url+"/todo/events", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Last-Event-ID", "3")
+		r, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer r.Body.Close()
+
+		e := readEvent(t, bufio.NewReader(r.Body))
+		assert.Equal(t, "4", e["id"])
+		assert.Equal(t, "delete", e["event"])
+	})
+
+	t.Run("ResumeFromStart", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url+"/todo/events", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Last-Event-ID", "0")
+		r, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer r.Body.Close()
+
+		stream := bufio.NewReader(r.Body)
+		for _, id := range []string{"1", "2", "3", "4"} {
+			assert.Equal(t, id, readEvent(t, stream)["id"])
+		}
+	})
+}
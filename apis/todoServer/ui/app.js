@@ -0,0 +1,147 @@
+"use strict";
+
+const items = document.getElementById("items");
+const empty = document.getElementById("empty");
+const error = document.getElementById("error");
+const statusBadge = document.getElementById("status");
+const form = document.getElementById("add");
+const input = document.getElementById("task");
+
+function showError(message) {
+  error.textContent = message;
+  error.hidden = !message;
+}
+
+async function request(method, url, body, headers = {}) {
+  const opts = { method, headers };
+  if (body !== undefined) {
+    opts.headers["Content-Type"] = "application/json";
+    opts.body = JSON.stringify(body);
+  }
+  const r = await fetch(url, opts);
+  if (!r.ok) {
+    throw new Error(`${method} ${url}: ${r.status} ${(await r.text()).trim()}`);
+  }
+  return r;
+}
+
+function render(list) {
+  items.replaceChildren();
+  list.forEach((item, i) => {
+    const id = i + 1;
+    const li = document.createElement("li");
+    li.classList.toggle("done", item.done);
+
+    const done = document.createElement("input");
+    done.type = "checkbox";
+    done.checked = item.done;
+    // the API cannot reopen a completed task
+    done.disabled = item.done;
+    done.title = "Complete";
+    done.addEventListener("change", () => complete(id, item));
+
+    const num = document.createElement("span");
+    num.className = "id";
+    num.textContent = id;
+
+    const task = document.createElement("span");
+    task.className = "task";
+    task.textContent = item.task;
+
+    const del = document.createElement("button");
+    del.textContent = "Delete";
+    del.addEventListener("click", () => remove(id, item));
+
+    li.append(done, num, task, del);
+    items.append(li);
+  });
+  empty.hidden = list.length > 0;
+}
+
+async function load() {
+  try {
+    const r = await request("GET", "/todo");
+    render((await r.json()).results);
+    showError("");
+  } catch (e) {
+    showError(e.message);
+  }
+}
+
+// pendingLoad batches the reloads triggered by a burst of events, like those
+// replayed after a reconnection, into one request.
+let pendingLoad;
+
+function scheduleLoad() {
+  clearTimeout(pendingLoad);
+  pendingLoad = setTimeout(load, 250);
+}
+
+// itemETag returns the ETag of the item shown at position id, failing when
+// another item is there now so a change never hits the wrong one.
+async function itemETag(id, item) {
+  const r = await request("GET", `/todo/${id}`);
+  const current = (await r.json()).results[0];
+  if (JSON.stringify(current) !== JSON.stringify(item)) {
+    throw new Error("The list has changed, please try again");
+  }
+  return r.headers.get("ETag");
+}
+
+async function complete(id, item) {
+  try {
+    const etag = await itemETag(id, item);
+    await request("PATCH", `/todo/${id}?complete`, undefined, { "If-Match": etag });
+  } catch (e) {
+    showError(e.message);
+  }
+  load();
+}
+
+async function remove(id, item) {
+  if (!confirm(`Delete "${item.task}"?`)) {
+    return;
+  }
+  try {
+    const etag = await itemETag(id, item);
+    await request("DELETE", `/todo/${id}`, undefined, { "If-Match": etag });
+  } catch (e) {
+    showError(e.message);
+  }
+  load();
+}
+
+form.addEventListener("submit", async (ev) => {
+  ev.preventDefault();
+  const task = input.value.trim();
+  if (!task) {
+    return;
+  }
+  try {
+    await request("POST", "/todo", { task });
+    input.value = "";
+  } catch (e) {
+    showError(e.message);
+  }
+  load();
+});
+
+function watch() {
+  const source = new EventSource("/todo/events");
+  source.addEventListener("open", () => {
+    statusBadge.textContent = "live";
+    statusBadge.classList.add("live");
+    scheduleLoad();
+  });
+  source.addEventListener("error", () => {
+    // EventSource reconnects on its own and sends Last-Event-ID
+    statusBadge.textContent = "offline";
+    statusBadge.classList.remove("live");
+  });
+  for (const type of ["add", "complete", "delete", "edit"]) {
+    source.addEventListener(type, scheduleLoad);
+  }
+}
+
+load();
+watch();
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUI(t *testing.T) {
+	ts := httptest.NewServer(uiHandler())
+	defer ts.Close()
+
+	testCases := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{"Index", http.MethodGet, "/", http.StatusOK, "text/html; charset=utf-8", `<script src="app.js">`},
+		{"Script", http.MethodGet, "/app.js", http.StatusOK, "text/javascript; charset=utf-8", `new EventSource("/todo/events")`},
+		{"ScriptConditional", http.MethodGet, "/app.js", http.StatusOK, "text/javascript; charset=utf-8", `{ "If-Match": etag }`},
+		{"Style", http.MethodGet, "/style.css", http.StatusOK, "text/css; charset=utf-8", "body {"},
+		{"Head", http.MethodHead, "/", http.StatusOK, "text/html; charset=utf-8", ""},
+		{"NotFound", http.MethodGet, "/missing.js", http.StatusNotFound, "text/plain; charset=utf-8", ""},
+		{"MethodNotAllowed", http.MethodPost, "/", http.StatusMethodNotAllowed, "text/plain; charset=utf-8", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, ts.URL+tc.path, nil)
+			assert.NoError(t, err)
+			r, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer r.Body.Close()
+
+			assert.Equal(t, tc.status, r.StatusCode)
+			assert.Equal(t, tc.contentType, r.Header.Get("Content-Type"))
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.Contains(t, string(body), tc.contains)
+		})
+	}
+}
+
+func TestUIOffline(t *testing.T) {
+	external := regexp.MustCompile(`(?i)(src|href)=["']?(https?:)?//|@import\s+url|https?://`)
+	err := fs.WalkDir(uiFiles, "ui", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := fs.ReadFile(uiFiles, path)
+		if err != nil {
+			return err
+		}
+		assert.False(t, external.Match(body), "%s references an external resource", path)
+		return nil
+	})
+	assert.NoError(t, err)
+}
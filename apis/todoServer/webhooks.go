@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	webhookAttempts    = 5
+	webhookBackoff     = time.Second
+	webhookMaxFailures = 50
+	webhookWorkers     = 4
+	webhookQueue       = 256
+)
+
+var errPrivateAddress = errors.New("webhooks cannot target loopback, private or link-local addresses")
+
+type delivery struct {
+	Event    event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Status   int       `json:"status"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+type webhook struct {
+	ID        int        `json:"id"`
+	URL       string     `json:"url"`
+	Events    []string   `json:"events"`
+	Secret    string     `json:"secret,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Failures  []delivery `json:"failures,omitempty"`
+}
+
+func (h *webhook) wants(typ string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, typ)
+}
+
+// public hides the secret and the failure log from listings.
+func (h webhook) public() webhook {
+	h.Secret = ""
+	h.Failures = nil
+	return h
+}
+
+type webhookList struct {
+	LastID   int       `json:"last_id"`
+	Webhooks []webhook `json:"webhooks"`
+}
+
+type webhookJob struct {
+	hook  webhook
+	event event
+}
+
+// webhooks stores subscriptions next to the todo file, keeping them in
+// memory once loaded, and delivers events to them from a bounded queue.
+type webhooks struct {
+	mu       sync.Mutex
+	file     string
+	list     *webhookList
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	// allowPrivate lets subscriptions target loopback and private
+	// addresses, which are refused by default so the server cannot be
+	// used to reach internal services.
+	allowPrivate bool
+
+	queue   chan webhookJob
+	start   sync.Once
+	pending sync.WaitGroup
+}
+
+func webhooksFile(todoFile string) string {
+	return strings.TrimSuffix(todoFile, filepath.Ext(todoFile)) + ".webhooks.json"
+}
+
+func newWebhooks(file string) *webhooks {
+	wh := &webhooks{
+		file:     file,
+		attempts: webhookAttempts,
+		backoff:  webhookBackoff,
+		queue:    make(chan webhookJob, webhookQueue),
+	}
+	// checking the address when connecting also covers redirects and
+	// names resolving to another address than at registration
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if addr, err := netip.ParseAddr(host); err == nil && !wh.allowPrivate && privateAddr(addr) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	wh.client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	return wh
+}
+
+func privateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast()
+}
+
+// checkTarget refuses URLs whose host is, or resolves to, a private address.
+func (wh *webhooks) checkTarget(u *url.URL) error {
+	if wh.allowPrivate {
+		return nil
+	}
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		addrs = append(addrs, addr)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname()); err != nil {
+			return fmt.Errorf("cannot resolve %s", u.Hostname())
+		}
+	}
+	for _, addr := range addrs {
+		if privateAddr(addr) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// load returns the subscriptions, read from the file the first time only.
+// Callers hold wh.mu.
+func (wh *webhooks) load() (*webhookList, error) {
+	if wh.list != nil {
+		return wh.list, nil
+	}
+	l := &webhookList{}
+	data, err := os.ReadFile(wh.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			wh.list = l
+			return l, nil
+		}
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, l); err != nil {
+			return nil, err
+		}
+	}
+	wh.list = l
+	return l, nil
+}
+
+// save writes l, the cached list, to the file. On failure the cache is
+// dropped so it is read again from the file.
+func (wh *webhooks) save(l *webhookList) error {
+	data, err := json.Marshal(l)
+	if err == nil {
+		err = os.WriteFile(wh.file, data, 0600)
+	}
+	if err != nil {
+		wh.list = nil
+	}
+	return err
+}
+
+// notify queues a delivery of e to every subscription that wants it. When
+// the queue is full the delivery is recorded as failed.
+func (wh *webhooks) notify(e event) {
+	wh.start.Do(func() {
+		for i := 0; i < webhookWorkers; i++ {
+			go func() {
+				for job := range wh.queue {
+					wh.deliver(job.hook, job.event)
+					wh.pending.Done()
+				}
+			}()
+		}
+	})
+
+	wh.mu.Lock()
+	l, err := wh.load()
+	var hooks []webhook
+	if err == nil {
+		for _, h := range l.Webhooks {
+			if h.wants(e.Type) {
+				hooks = append(hooks, h)
+			}
+		}
+	}
+	wh.mu.Unlock()
+	if err != nil {
+		log.Printf("webhooks: cannot load subscriptions: %s", err)
+		return
+	}
+
+	for _, h := range hooks {
+		wh.pending.Add(1)
+		select {
+		case wh.queue <- webhookJob{hook: h, event: e}:
+		default:
+			wh.pending.Done()
+			d := delivery{Event: e, Error: "delivery queue full", FailedAt: time.Now()}
+			log.Printf("webhooks: delivery of event %d to %s failed: %s", e.ID, h.URL, d.Error)
+			if err := wh.recordFailure(h.ID, d); err != nil {
+				log.Printf("webhooks: cannot record failure: %s", err)
+			}
+		}
+	}
+}
+
+// retryable reports whether a delivery answered with status can succeed
+// later. Other client errors are final.
+func retryable(status int) bool {
+	return status < 400 || status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+func (wh *webhooks) deliver(h webhook, e event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("webhooks: cannot encode event %d: %s", e.ID, err)
+		return
+	}
+
+	d := delivery{Event: e}
+	backoff := wh.backoff
+	for d.Attempts < wh.attempts {
+		if d.Attempts > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		d.Attempts++
+		d.Status, err = wh.post(h, e, body)
+		if err == nil {
+			return
+		}
+		d.Error = err.Error()
+		if !retryable(d.Status) {
+			break
+		}
+	}
+
+	d.FailedAt = time.Now()
+	log.Printf("webhooks: delivery of event %d to %s failed: %s", e.ID, h.URL, d.Error)
+	if err := wh.recordFailure(h.ID, d); err != nil {
+		log.Printf("webhooks: cannot record failure: %s", err)
+	}
+}
+
+func (wh *webhooks) post(h webhook, e event, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Todo-Event", e.Type)
+	req.Header.Set("X-Todo-Delivery", strconv.FormatInt(e.ID, 10))
+	req.Header.Set("X-Todo-Signature", "sha256="+sign(h.Secret, body))
+
+	r, err := wh.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Body.Close()
+	io.Copy(io.Discard, r.Body)
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return r.StatusCode, fmt.Errorf("unexpected status %d", r.StatusCode)
+	}
+	return r.StatusCode, nil
+}
+
+func (wh *webhooks) recordFailure(id int, d delivery) error {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	l, err := wh.load()
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(l.Webhooks, func(h webhook) bool { return h.ID == id })
+	if idx < 0 {
+		// unsubscribed while delivering
+		return nil
+	}
+	h := &l.Webhooks[idx]
+	h.Failures = append(h.Failures, d)
+	if len(h.Failures) > webhookMaxFailures {
+		h.Failures = h.Failures[len(h.Failures)-webhookMaxFailures:]
+	}
+	return wh.save(l)
+}
+
+// sign returns the hex encoded HMAC-SHA256 of body keyed with secret.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func webhooksRouter(wh *webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" && r.Method == http.MethodPost {
+			// validating the URL may resolve it, not to be done locked
+			addWebhookHandler(w, r, wh)
+			return
+		}
+
+		wh.mu.Lock()
+		defer wh.mu.Unlock()
+		l, err := wh.load()
+		if err != nil {
+			replyError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if r.URL.Path == "" {
+			switch r.Method {
+			case http.MethodGet:
+				hooks := make([]webhook, 0, len(l.Webhooks))
+				for _, h := range l.Webhooks {
+					hooks = append(hooks, h.public())
+				}
+				replyJSONContent(w, r, http.StatusOK, hooks)
+			default:
+				replyError(w, r, http.StatusMethodNotAllowed, "method not supported")
+			}
+			return
+		}
+
+		idStr, sub, _ := strings.Cut(r.URL.Path, "/")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			replyError(w, r, http.StatusBadRequest, fmt.Sprintf("%s: Invalid ID: %s", ErrInvalidData, err))
+			return
+		}
+		idx := slices.IndexFunc(l.Webhooks, func(h webhook) bool { return h.ID == id })
+		if idx < 0 || (sub != "" && sub != "failures") {
+			replyError(w, r, http.StatusNotFound, fmt.Sprintf("%s: webhook %d", ErrNotFound, id))
+			return
+		}
+
+		switch {
+		case sub == "failures" && r.Method == http.MethodGet:
+			failures := l.Webhooks[idx].Failures
+			if failures == nil {
+				failures = []delivery{}
+			}
+			replyJSONContent(w, r, http.StatusOK, failures)
+		case sub == "" && r.Method == http.MethodGet:
+			replyJSONContent(w, r, http.StatusOK, l.Webhooks[idx].public())
+		case sub == "" && r.Method == http.MethodDelete:
+			l.Webhooks = slices.Delete(l.Webhooks, idx, idx+1)
+			if err := wh.save(l); err != nil {
+				replyError(w, r, http.StatusInternalServerError, err.Error())
+				return
+			}
+			replyTextContent(w, r, http.StatusNoContent, "")
+		default:
+			replyError(w, r, http.StatusMethodNotAllowed, "method not supported")
+		}
+	}
+}
+
+func addWebhookHandler(w http.ResponseWriter, r *http.Request, wh *webhooks) {
+	h := webhook{}
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		replyError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %s", err))
+		return
+	}
+
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		replyError(w, r, http.StatusBadRequest, fmt.Sprintf("%s: Invalid URL: %q", ErrInvalidData, h.URL))
+		return
+	}
+	if err := wh.checkTarget(u); err != nil {
+		replyError(w, r, http.StatusBadRequest, fmt.Sprintf("%s: Invalid URL: %q: %s", ErrInvalidData, h.URL, err))
+		return
+	}
+	for _, e := range h.Events {
+		if e != EventAdd && e != EventComplete && e != EventDelete && e != EventEdit {
+			replyError(w, r, http.StatusBadRequest, fmt.Sprintf("%s: Invalid event: %q", ErrInvalidData, e))
+			return
+		}
+	}
+	if h.Secret == "" {
+		if h.Secret, err = newSecret(); err != nil {
+			replyError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	l, err := wh.load()
+	if err != nil {
+		replyError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	l.LastID++
+	h.ID = l.LastID
+	h.CreatedAt = time.Now()
+	h.Failures = nil
+	if h.Events == nil {
+		h.Events = []string{}
+	}
+	l.Webhooks = append(l.Webhooks, h)
+	if err := wh.save(l); err != nil {
+		replyError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// the secret is only revealed on creation
+	replyJSONContent(w, r, http.StatusCreated, h)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addWebhook(t *testing.T, url, body string) webhook {
+	t.Helper()
+	r, err := http.Post(url+"/webhooks", "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	defer r.Body.Close()
+	assert.Equal(t, http.StatusCreated, r.StatusCode)
+
+	h := webhook{}
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&h))
+	return h
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	url, cleanup := setupApi(t)
+	defer cleanup()
+
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	deliveries := make(chan received, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		deliveries <- received{r.Header, body}
+	}))
+	defer receiver.Close()
+
+	h := addWebhook(t, url, fmt.Sprintf(`{"url":%q,"events":["complete"],"secret":"s3cret"}`, receiver.URL))
+	assert.Equal(t, 1, h.ID)
+	assert.Equal(t, "s3cret", h.Secret)
+	assert.Equal(t, []string{"complete"}, h.Events)
+
+	t.Run("Filtered", func(t *testing.T) {
+		r, err := http.Post(url+"/todo", "application/json", strings.NewReader(`{"task":"Task number 3"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, r.StatusCode)
+
+		req, err := http.NewRequest(http.MethodPatch, url+"/todo/3?complete", nil)
+		assert.NoError(t, err)
+		_, err = http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		select {
+		case d := <-deliveries:
+			assert.Equal(t, "complete", d.header.Get("X-Todo-Event"))
+			assert.Equal(t, "sha256="+sign("s3cret", d.body), d.header.Get("X-Todo-Signature"))
+			e := event{}
+			assert.NoError(t, json.Unmarshal(d.body, &e))
+			assert.Equal(t, 3, e.ItemID)
+			assert.Equal(t, "Task number 3", e.Task)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no delivery")
+		}
+		assert.Len(t, deliveries, 0)
+	})
+
+	t.Run("SecretHidden", func(t *testing.T) {
+		r, err := http.Get(url + "/webhooks")
+		assert.NoError(t, err)
+		defer r.Body.Close()
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(body), "s3cret")
+	})
+}
+
+func webhooksMux(wh *webhooks) http.Handler {
+	m := http.NewServeMux()
+	m.Handle("/webhooks", http.StripPrefix("/webhooks", webhooksRouter(wh)))
+	m.Handle("/webhooks/", http.StripPrefix("/webhooks/", webhooksRouter(wh)))
+	return m
+}
+
+func TestWebhookFailures(t *testing.T) {
+	hookFile, err := os.CreateTemp("", "webhooks")
+	assert.NoError(t, err)
+	defer os.Remove(hookFile.Name())
+
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	wh := newWebhooks(hookFile.Name())
+	wh.attempts = 3
+	wh.backoff = time.Millisecond
+	wh.allowPrivate = true
+	ts := httptest.NewServer(webhooksMux(wh))
+	defer ts.Close()
+
+	addWebhook(t, ts.URL, fmt.Sprintf(`{"url":%q}`, receiver.URL))
+	wh.notify(event{ID: 7, Type: EventAdd, ItemID: 1, Task: "Task 1"})
+	wh.pending.Wait()
+	assert.Equal(t, 3, attempts)
+
+	// subscriptions and failures survive a restart
+	wh = newWebhooks(hookFile.Name())
+	wh.allowPrivate = true
+	ts.Config.Handler = webhooksMux(wh)
+
+	r, err := http.Get(ts.URL + "/webhooks/1/failures")
+	assert.NoError(t, err)
+	defer r.Body.Close()
+	failures := []delivery{}
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&failures))
+	assert.Len(t, failures, 1)
+	assert.Equal(t, int64(7), failures[0].Event.ID)
+	assert.Equal(t, 3, failures[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, failures[0].Status)
+
+	r, err = http.Post(ts.URL+"/webhooks", "application/json", bytes.NewBufferString(`{"url":"http://localhost","events":["rename"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, r.StatusCode)
+}
+
+func TestWebhookClientError(t *testing.T) {
+	hookFile, err := os.CreateTemp("", "webhooks")
+	assert.NoError(t, err)
+	defer os.Remove(hookFile.Name())
+
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	wh := newWebhooks(hookFile.Name())
+	wh.backoff = time.Millisecond
+	wh.allowPrivate = true
+	ts := httptest.NewServer(webhooksMux(wh))
+	defer ts.Close()
+	addWebhook(t, ts.URL, fmt.Sprintf(`{"url":%q}`, receiver.URL))
+
+	// subscriptions are read from memory, not from the file
+	assert.NoError(t, os.WriteFile(hookFile.Name(), []byte("{"), 0600))
+	wh.notify(event{ID: 1, Type: EventAdd, ItemID: 1, Task: "Task 1"})
+	wh.pending.Wait()
+	assert.Equal(t, 1, attempts)
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	assert.Equal(t, 1, wh.list.Webhooks[0].Failures[0].Attempts)
+	assert.Equal(t, http.StatusGone, wh.list.Webhooks[0].Failures[0].Status)
+}
+
+func TestWebhookPrivateTargets(t *testing.T) {
+	hookFile, err := os.CreateTemp("", "webhooks")
+	assert.NoError(t, err)
+	defer os.Remove(hookFile.Name())
+
+	delivered := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered = true
+	}))
+	defer receiver.Close()
+
+	wh := newWebhooks(hookFile.Name())
+	wh.attempts = 1
+	ts := httptest.NewServer(webhooksMux(wh))
+	defer ts.Close()
+
+	for _, target := range []string{"http://127.0.0.1:8080/hook", "http://10.1.2.3/hook", "http://[::1]/hook", "http://169.254.169.254/latest", "http://[::ffff:192.168.0.1]/hook"} {
+		r, err := http.Post(ts.URL+"/webhooks", "application/json", strings.NewReader(fmt.Sprintf(`{"url":%q}`, target)))
+		assert.NoError(t, err)
+		r.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, r.StatusCode, target)
+	}
+
+	// registered while allowed, refused when connecting
+	wh.allowPrivate = true
+	addWebhook(t, ts.URL, fmt.Sprintf(`{"url":%q}`, receiver.URL))
+	wh.allowPrivate = false
+	wh.notify(event{ID: 1, Type: EventAdd, ItemID: 1, Task: "Task 1"})
+	wh.pending.Wait()
+	assert.False(t, delivered)
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	assert.Contains(t, wh.list.Webhooks[0].Failures[0].Error, errPrivateAddress.Error())
+}
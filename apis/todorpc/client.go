@@ -0,0 +1,165 @@
+package todorpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient returns a client for the service at baseURL. A nil httpClient
+// uses http.DefaultClient; set a timeout on it only if Watch is not used.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    httpClient,
+	}
+}
+
+func (c *Client) Add(ctx context.Context, req *AddRequest) (*AddResponse, error) {
+	resp := &AddResponse{}
+	return resp, c.call(ctx, AddProcedure, req, resp)
+}
+
+func (c *Client) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	resp := &ListResponse{}
+	return resp, c.call(ctx, ListProcedure, req, resp)
+}
+
+func (c *Client) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	resp := &GetResponse{}
+	return resp, c.call(ctx, GetProcedure, req, resp)
+}
+
+func (c *Client) Complete(ctx context.Context, req *CompleteRequest) (*CompleteResponse, error) {
+	resp := &CompleteResponse{}
+	return resp, c.call(ctx, CompleteProcedure, req, resp)
+}
+
+func (c *Client) Edit(ctx context.Context, req *EditRequest) (*EditResponse, error) {
+	resp := &EditResponse{}
+	return resp, c.call(ctx, EditProcedure, req, resp)
+}
+
+func (c *Client) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	resp := &DeleteResponse{}
+	return resp, c.call(ctx, DeleteProcedure, req, resp)
+}
+
+func (c *Client) call(ctx context.Context, procedure string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := c.post(ctx, procedure, ContentTypeUnary, body)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return readError(r)
+	}
+	if err := json.NewDecoder(r.Body).Decode(resp); err != nil {
+		return &Error{Code: CodeInternal, Message: fmt.Sprintf("invalid response: %s", err)}
+	}
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, procedure, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+procedure, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	r, err := c.http.Do(req)
+	if err != nil {
+		return nil, &Error{Code: CodeUnavailable, Message: err.Error()}
+	}
+	return r, nil
+}
+
+func readError(r *http.Response) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return &Error{Code: codeFromStatus(r.StatusCode), Message: err.Error()}
+	}
+	e := &Error{}
+	if err := json.Unmarshal(body, e); err != nil || e.Code == "" {
+		return &Error{Code: codeFromStatus(r.StatusCode), Message: strings.TrimSpace(string(body))}
+	}
+	return e
+}
+
+// WatchStream receives the events of a Watch call.
+type WatchStream struct {
+	body io.ReadCloser
+	err  error
+}
+
+// Watch opens a server stream of changes after req.LastEventID.
+func (c *Client) Watch(ctx context.Context, req *WatchRequest) (*WatchStream, error) {
+	msg, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	body := bytes.Buffer{}
+	if err := WriteEnvelope(&body, false, msg); err != nil {
+		return nil, err
+	}
+
+	r, err := c.post(ctx, WatchProcedure, ContentTypeStream, body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		defer r.Body.Close()
+		return nil, readError(r)
+	}
+	return &WatchStream{body: r.Body}, nil
+}
+
+// Receive returns the next event. It returns io.EOF when the server ends
+// the stream cleanly and an *Error when it ends it with an error.
+func (s *WatchStream) Receive() (*Event, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	end, msg, err := ReadEnvelope(s.body)
+	if err != nil {
+		s.err = &Error{Code: CodeUnavailable, Message: err.Error()}
+		return nil, s.err
+	}
+	if end {
+		trailer := struct {
+			Error *Error `json:"error"`
+		}{}
+		s.err = io.EOF
+		if err := json.Unmarshal(msg, &trailer); err == nil && trailer.Error != nil {
+			s.err = trailer.Error
+		}
+		return nil, s.err
+	}
+
+	e := &Event{}
+	if err := json.Unmarshal(msg, e); err != nil {
+		s.err = &Error{Code: CodeInternal, Message: fmt.Sprintf("invalid event: %s", err)}
+		return nil, s.err
+	}
+	return e, nil
+}
+
+func (s *WatchStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,209 @@
+// Package todorpc defines the messages of the todo RPC service and a client
+// for it.
+//
+// The service follows the Connect protocol with the JSON codec: unary calls
+// are a POST of the request message to /<service>/<method> answered with the
+// response message, and errors are a JSON body with a code and a message.
+// Server streams use the application/connect+json envelope format.
+package todorpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const ServiceName = "todo.v1.TodoService"
+
+const (
+	AddProcedure      = "/" + ServiceName + "/Add"
+	ListProcedure     = "/" + ServiceName + "/List"
+	GetProcedure      = "/" + ServiceName + "/Get"
+	CompleteProcedure = "/" + ServiceName + "/Complete"
+	DeleteProcedure   = "/" + ServiceName + "/Delete"
+	EditProcedure     = "/" + ServiceName + "/Edit"
+	WatchProcedure    = "/" + ServiceName + "/Watch"
+)
+
+const (
+	ContentTypeUnary  = "application/json"
+	ContentTypeStream = "application/connect+json"
+)
+
+type Item struct {
+	ID          int       `json:"id"`
+	Task        string    `json:"task"`
+	Done        bool      `json:"done"`
+	CreatedAt   time.Time `json:"created_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	ETag        string    `json:"etag"`
+}
+
+type AddRequest struct {
+	Task string `json:"task"`
+}
+
+type AddResponse struct {
+	Item Item `json:"item"`
+}
+
+type ListRequest struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type ListResponse struct {
+	Items        []Item `json:"items"`
+	TotalResults int    `json:"total_results"`
+}
+
+type GetRequest struct {
+	ID int `json:"id"`
+}
+
+type GetResponse struct {
+	Item Item `json:"item"`
+}
+
+// CompleteRequest, EditRequest and DeleteRequest fail with CodeFailedPrecondition when
+// IfMatch is set and differs from the current ETag of the item.
+type CompleteRequest struct {
+	ID      int    `json:"id"`
+	IfMatch string `json:"if_match"`
+}
+
+type CompleteResponse struct {
+	Item Item `json:"item"`
+}
+
+type EditRequest struct {
+	ID      int    `json:"id"`
+	Task    string `json:"task"`
+	IfMatch string `json:"if_match"`
+}
+
+type EditResponse struct {
+	Item Item `json:"item"`
+}
+
+type DeleteRequest struct {
+	ID      int    `json:"id"`
+	IfMatch string `json:"if_match"`
+}
+
+type DeleteResponse struct{}
+
+type WatchRequest struct {
+	// LastEventID resumes the stream after that event, 0 streams new
+	// events only.
+	LastEventID int64 `json:"last_event_id"`
+}
+
+type Event struct {
+	ID     int64     `json:"id"`
+	Type   string    `json:"type"`
+	ItemID int       `json:"item_id"`
+	Task   string    `json:"task"`
+	Date   time.Time `json:"date"`
+}
+
+type Code string
+
+const (
+	CodeInvalidArgument    Code = "invalid_argument"
+	CodeNotFound           Code = "not_found"
+	CodeFailedPrecondition Code = "failed_precondition"
+	CodeResourceExhausted  Code = "resource_exhausted"
+	CodeUnimplemented      Code = "unimplemented"
+	CodeInternal           Code = "internal"
+	CodeUnavailable        Code = "unavailable"
+)
+
+// HTTPStatus is the status a unary error is sent with.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeInvalidArgument, CodeFailedPrecondition:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case CodeUnimplemented:
+		return http.StatusNotFound
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// codeFromStatus maps an HTTP status without a Connect error body to a code.
+func codeFromStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidArgument
+	case http.StatusNotFound:
+		return CodeUnimplemented
+	case http.StatusTooManyRequests:
+		return CodeResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUnavailable
+	}
+	return CodeInternal
+}
+
+type Error struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// CodeOf returns the code of an RPC error, or CodeInternal for other errors.
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+const (
+	flagEndStream = 0x02
+	maxEnvelope   = 4 << 20
+)
+
+// WriteEnvelope frames one message of a Connect stream.
+func WriteEnvelope(w io.Writer, endStream bool, msg []byte) error {
+	header := make([]byte, 5)
+	if endStream {
+		header[0] = flagEndStream
+	}
+	binary.BigEndian.PutUint32(header[1:], uint32(len(msg)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// ReadEnvelope reads one framed message and reports whether it ends the stream.
+func ReadEnvelope(r io.Reader) (bool, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxEnvelope {
+		return false, nil, fmt.Errorf("message of %d bytes exceeds %d", size, maxEnvelope)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return false, nil, err
+	}
+	return header[0]&flagEndStream != 0, msg, nil
+}
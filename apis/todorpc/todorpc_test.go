@@ -0,0 +1,74 @@
+package todorpc_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go-cmd-book/apis/todorpc"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope(t *testing.T) {
+	buf := bytes.Buffer{}
+	assert.NoError(t, todorpc.WriteEnvelope(&buf, false, []byte(`{"id":1}`)))
+	assert.NoError(t, todorpc.WriteEnvelope(&buf, true, []byte(`{}`)))
+	assert.Equal(t, []byte{0, 0, 0, 0, 8}, buf.Bytes()[:5])
+
+	end, msg, err := todorpc.ReadEnvelope(&buf)
+	assert.NoError(t, err)
+	assert.False(t, end)
+	assert.Equal(t, `{"id":1}`, string(msg))
+
+	end, msg, err = todorpc.ReadEnvelope(&buf)
+	assert.NoError(t, err)
+	assert.True(t, end)
+	assert.Equal(t, `{}`, string(msg))
+
+	_, _, err = todorpc.ReadEnvelope(&buf)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestClientErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case todorpc.GetProcedure:
+			w.Header().Set("Content-Type", todorpc.ContentTypeUnary)
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"code":"not_found","message":"ID 3 not found"}`)
+		case todorpc.WatchProcedure:
+			w.Header().Set("Content-Type", todorpc.ContentTypeStream)
+			todorpc.WriteEnvelope(w, false, []byte(`{"id":1,"type":"add","item_id":1,"task":"Task 1"}`))
+			todorpc.WriteEnvelope(w, true, []byte(`{"error":{"code":"unavailable","message":"slow"}}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer ts.Close()
+	c := todorpc.NewClient(ts.URL, nil)
+	ctx := context.Background()
+
+	_, err := c.Get(ctx, &todorpc.GetRequest{ID: 3})
+	assert.EqualError(t, err, "not_found: ID 3 not found")
+	assert.Equal(t, todorpc.CodeNotFound, todorpc.CodeOf(err))
+
+	_, err = c.List(ctx, &todorpc.ListRequest{})
+	assert.Equal(t, todorpc.CodeResourceExhausted, todorpc.CodeOf(err))
+
+	stream, err := c.Watch(ctx, &todorpc.WatchRequest{})
+	assert.NoError(t, err)
+	defer stream.Close()
+	e, err := stream.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, "Task 1", e.Task)
+	_, err = stream.Receive()
+	assert.Equal(t, todorpc.CodeUnavailable, todorpc.CodeOf(err))
+
+	ts.Close()
+	_, err = c.List(ctx, &todorpc.ListRequest{})
+	assert.Equal(t, todorpc.CodeUnavailable, todorpc.CodeOf(err))
+}
@@ -2,13 +2,24 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"go-cmd-book/pScan/scan"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,24 +28,24 @@ func TestHostActions(t *testing.T) {
 	assert.NoError(t, err)
 	t.Run("add hosts", func(t *testing.T) {
 		out := bytes.Buffer{}
-		err := addAction(&out, hostFile.Name(), []string{"host1", "host2"})
+		err := addAction(&out, hostFile.Name(), []string{"host1", "host2"}, scan.Host{})
 		assert.NoError(t, err)
 		assert.Equal(t, "Added host: host1\nAdded host: host2\n", out.String())
 	})
 
 	t.Run("add empty hosts", func(t *testing.T) {
 		out := &bytes.Buffer{}
-		addAction(out, hostFile.Name(), []string{})
+		addAction(out, hostFile.Name(), []string{}, scan.Host{})
 		assert.NoError(t, err)
 		assert.Equal(t, "", out.String())
 	})
 
 	t.Run("list hosts", func(t *testing.T) {
-		err := addAction(&bytes.Buffer{}, hostFile.Name(), []string{"host3"})
+		err := addAction(&bytes.Buffer{}, hostFile.Name(), []string{"host3"}, scan.Host{})
 		assert.NoError(t, err)
 
 		out := &bytes.Buffer{}
-		err = listAction(out, hostFile.Name())
+		err = listAction(out, hostFile.Name(), nil, nil)
 		assert.NoError(t, err)
 		assert.Equal(t, "host1\nhost2\nhost3\n", out.String())
 	})
@@ -51,7 +62,29 @@ func TestHostActions(t *testing.T) {
 
 	db, err := os.ReadFile(hostFile.Name())
 	assert.NoError(t, err)
-	assert.Equal(t, "host2\nhost3\n", string(db))
+	assert.Equal(t, "hosts:\n  - host: host2\n  - host: host3\n", string(db))
+}
+
+func TestImportAction(t *testing.T) {
+	dir := t.TempDir()
+	hostsFile := filepath.Join(dir, "pScan.hosts")
+	assert.NoError(t, addAction(&bytes.Buffer{}, hostsFile, []string{"host1"}, scan.Host{}))
+
+	importFile := filepath.Join(dir, "import.txt")
+	assert.NoError(t, os.WriteFile(importFile, []byte("# office\n10.0.0.0/30\n\nHOST1\n2001:db8::1\n"), 0644))
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, importAction(out, hostsFile, importFile, scan.Host{}))
+	assert.Equal(t, "Added host: 10.0.0.0/30\nSkipped existing host: HOST1\nAdded host: 2001:db8::1\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, listAction(out, hostsFile, nil, nil))
+	assert.Equal(t, "host1\n10.0.0.0/30\n2001:db8::1\n", out.String())
+
+	assert.NoError(t, os.WriteFile(importFile, []byte("10.0.0.9\n10.0.0.0/33\n"), 0644))
+	err := importAction(out, hostsFile, importFile, scan.Host{})
+	assert.ErrorIs(t, err, scan.ErrInvalidHost)
+	assert.ErrorContains(t, err, "import.txt:2")
 }
 
 func TestScanAction(t *testing.T) {
@@ -71,10 +104,439 @@ func TestScanAction(t *testing.T) {
 	port, err := strconv.ParseInt(portStr, 10, 0)
 	assert.NoError(t, err)
 
-	err = scanAction(out, hostFile.Name(), []int{int(port)}, "tcp", false)
+	cfg := scanConfig{hostsFile: hostFile.Name(), ports: []int{int(port)}, proto: "tcp", format: outputText}
+	err = scanAction(context.Background(), out, cfg)
 	assert.NoError(t, err)
 
 	expected := fmt.Sprintf("localhost: \n\t%d: open\n\n", port)
 	assert.Equal(t, expected, out.String())
 
+	t.Run("host not found", func(t *testing.T) {
+		assert.NoError(t, hl.Add("257.257.257.257"))
+		assert.NoError(t, hl.Save(hostFile.Name()))
+		out := &bytes.Buffer{}
+		err = scanAction(context.Background(), out, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, expected+"257.257.257.257: host not found\n\n", out.String())
+	})
+}
+
+func TestOutput(t *testing.T) {
+	started := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	report := scan.Report{
+		Protocol: "tcp",
+		Ports:    []int{22, 80},
+		Started:  started,
+		Finished: started.Add(1500 * time.Millisecond),
+		Results: []scan.Results{
+			{Host: "10.0.0.1", PortStates: []scan.PortState{
+				{Port: 22, State: scan.Open, Service: "ssh", Version: "OpenSSH_9.6p1", Banner: "SSH-2.0-OpenSSH_9.6p1"},
+				{Port: 80},
+			}},
+			{Host: "unknown.example", NotFound: true},
+			{Host: "db.example", Address: "2001:db8::5", PortStates: []scan.PortState{{Port: 22}, {Port: 80}}},
+		},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, writeReport(out, outputText, report))
+		assert.Equal(t, "10.0.0.1: \n\t22: open ssh OpenSSH_9.6p1\n\t80: closed\n\nunknown.example: host not found\n\n"+
+			"db.example: \n\t22: closed\n\t80: closed\n\n", out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, writeReport(out, outputJSON, report))
+		assert.Contains(t, out.String(), `"state": "open"`)
+		assert.Contains(t, out.String(), `"not_found": true`)
+
+		decoded := scan.Report{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		assert.Equal(t, report, decoded)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, writeReport(out, outputCSV, report))
+		expected := "host,protocol,port,state,service,version,banner\n" +
+			"10.0.0.1,tcp,22,open,ssh,OpenSSH_9.6p1,SSH-2.0-OpenSSH_9.6p1\n" +
+			"10.0.0.1,tcp,80,closed,,,\n" +
+			"unknown.example,tcp,,not found,,,\n" +
+			"db.example,tcp,22,closed,,,\n" +
+			"db.example,tcp,80,closed,,,\n"
+		assert.Equal(t, expected, out.String())
+	})
+
+	t.Run("xml", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, writeReport(out, outputXML, report))
+		assert.True(t, strings.HasPrefix(out.String(), xml.Header+"<nmaprun"))
+
+		run := nmapRun{}
+		assert.NoError(t, xml.Unmarshal(out.Bytes(), &run))
+		assert.Equal(t, int64(1715680800), run.Start)
+		assert.Equal(t, nmapScanInfo{Type: "connect", Protocol: "tcp", NumServices: 2, Services: "22,80"}, run.ScanInfo)
+		assert.Equal(t, nmapHosts{Up: 2, Down: 1, Total: 3}, run.RunStats.Hosts)
+		assert.Equal(t, 1.5, run.RunStats.Finished.Elapsed)
+		assert.Len(t, run.Hosts, 3)
+		assert.Equal(t, []nmapAddress{{Addr: "10.0.0.1", AddrType: "ipv4"}}, run.Hosts[0].Addresses)
+		assert.Equal(t, []nmapPort{
+			{Protocol: "tcp", PortID: 22, State: nmapStatus{State: "open", Reason: "syn-ack"},
+				Service: &nmapService{Name: "ssh", Product: "OpenSSH_9.6p1", Method: "probed", Conf: 10},
+				Scripts: []nmapScript{{ID: "banner", Output: "SSH-2.0-OpenSSH_9.6p1"}}},
+			{Protocol: "tcp", PortID: 80, State: nmapStatus{State: "closed", Reason: "conn-refused"}},
+		}, run.Hosts[0].Ports)
+		assert.Equal(t, nmapStatus{State: "down", Reason: "no-resolve"}, run.Hosts[1].Status)
+		assert.Equal(t, []nmapHostname{{Name: "unknown.example", Type: "user"}}, run.Hosts[1].Hostnames)
+		assert.Empty(t, run.Hosts[1].Addresses)
+		assert.Equal(t, []nmapHostname{{Name: "db.example", Type: "user"}}, run.Hosts[2].Hostnames)
+		assert.Equal(t, []nmapAddress{{Addr: "2001:db8::5", AddrType: "ipv6"}}, run.Hosts[2].Addresses)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Error(t, validateOutput("yaml"))
+	})
+}
+
+func TestPortStates(t *testing.T) {
+	results := []scan.Results{{Host: "10.0.0.1", PortStates: []scan.PortState{
+		{Port: 22, State: scan.Open},
+		{Port: 80},
+		{Port: 443, State: scan.Filtered},
+		{Port: 53, State: scan.OpenFiltered},
+		{Port: 8080, State: scan.Unreachable},
+	}}}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printResults(out, results))
+	assert.Equal(t, "10.0.0.1: \n\t22: open\n\t80: closed\n\t443: filtered\n\t53: open|filtered\n\t8080: unreachable\n\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, printResults(out, filterClosedPorts(results)))
+	assert.Equal(t, "10.0.0.1: \n\t22: open\n\t53: open|filtered\n\n", out.String())
+
+	assert.Equal(t, "no-response", portReason("tcp", results[0].PortStates[2]))
+	assert.Equal(t, "host-unreach", portReason("tcp", results[0].PortStates[4]))
+}
+
+func TestScanPorts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []int
+		expErr   string
+	}{
+		{name: "default", expected: []int{22, 80, 443}},
+		{name: "mixed", args: []string{"-p", "web,8000-8001,9200"}, expected: []int{80, 443, 8000, 8008, 8080, 8443, 8888, 8001, 9200}},
+		{name: "range only", args: []string{"-r", "1-3"}, expected: []int{1, 2, 3}},
+		{name: "top", args: []string{"--top", "3"}, expected: []int{80, 23, 443}},
+		{name: "top udp", args: []string{"--top", "2", "--protocol", "udp"}, expected: []int{631, 161}},
+		{name: "ports and top", args: []string{"-p", "22,8080", "--top", "3"}, expected: []int{22, 8080, 80, 23, 443}},
+		{name: "reversed range", args: []string{"-r", "1024-1"}, expErr: "the end is before the start"},
+		{name: "out of range", args: []string{"-p", "70000"}, expErr: "should be from 1 to 65535"},
+		{name: "not a range", args: []string{"-r", "web"}, expErr: "invalid port range web"},
+		{name: "top too large", args: []string{"--top", "1000"}, expErr: "invalid top 1000"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addScanFlags(cmd)
+			assert.NoError(t, cmd.ParseFlags(tc.args))
+			cfg, err := scanFlags(cmd)
+			if tc.expErr != "" {
+				assert.ErrorContains(t, err, tc.expErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, cfg.ports)
+			assert.Equal(t, len(tc.args) > 0, cfg.forcePorts)
+		})
+	}
+}
+
+func TestHistoryActions(t *testing.T) {
+	dir := t.TempDir()
+	historyDir := filepath.Join(dir, "history")
+	hostsFile := filepath.Join(dir, "pScan.hosts")
+	assert.NoError(t, addAction(&bytes.Buffer{}, hostsFile, []string{"127.0.0.1"}, scan.Host{}))
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, historyAction(out, historyDir))
+	assert.Equal(t, "No runs saved in "+historyDir+"\n", out.String())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	cfg := scanConfig{hostsFile: hostsFile, ports: []int{port}, proto: "tcp", format: outputText, historyDir: historyDir}
+
+	assert.NoError(t, scanAction(context.Background(), &bytes.Buffer{}, cfg))
+	assert.NoError(t, scanAction(context.Background(), &bytes.Buffer{}, cfg))
+	ln.Close()
+	// runs keep closed ports even when they are not displayed
+	cfg.filterClosed = true
+	assert.NoError(t, scanAction(context.Background(), &bytes.Buffer{}, cfg))
+
+	// the results are written even when the run cannot be saved
+	broken := cfg
+	broken.historyDir = hostsFile
+	out.Reset()
+	assert.NoError(t, scanAction(context.Background(), out, broken))
+	assert.Equal(t, "127.0.0.1: \n\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, historyAction(out, historyDir))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, []string{"ID", "STARTED", "DURATION", "PROTOCOL", "HOSTS", "OPEN"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"1", "tcp", "1", "1"}, slices.Delete(strings.Fields(lines[1]), 1, 4))
+	assert.Equal(t, []string{"3", "tcp", "1", "0"}, slices.Delete(strings.Fields(lines[3]), 1, 4))
+
+	t.Run("no changes", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, diffAction(out, historyDir, "1", "2", outputText))
+		assert.Equal(t, "No changes between runs 1 and 2\n", out.String())
+	})
+
+	t.Run("closed port", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		err := diffAction(out, historyDir, "previous", "latest", outputText)
+		assert.ErrorIs(t, err, errChanges)
+		assert.Equal(t, fmt.Sprintf("Changes between runs 2 and 3:\n\t- 127.0.0.1:%d open -> closed\n", port), out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		err := diffAction(out, historyDir, "3", "1", outputJSON)
+		assert.ErrorIs(t, err, errChanges)
+		expected := fmt.Sprintf(`{
+  "from": 3,
+  "to": 1,
+  "hosts_up": [],
+  "hosts_down": [],
+  "opened": [
+    {
+      "host": "127.0.0.1",
+      "port": %d,
+      "from": "closed",
+      "to": "open"
+    }
+  ],
+  "closed": []
+}
+`, port)
+		assert.Equal(t, expected, out.String())
+	})
+
+	t.Run("unknown run", func(t *testing.T) {
+		err := diffAction(&bytes.Buffer{}, historyDir, "1", "9", outputText)
+		assert.ErrorIs(t, err, scan.ErrNoRun)
+	})
+}
+
+func TestScanGroups(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	open := ln.Addr().(*net.TCPAddr).Port
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closed := ln2.Addr().(*net.TCPAddr).Port
+	ln2.Close()
+
+	hostsFile := filepath.Join(t.TempDir(), "pScan.hosts")
+	inv := &scan.Inventory{Groups: map[string]scan.Group{"web": {Ports: []int{open}}}}
+	assert.NoError(t, inv.Save(hostsFile))
+	assert.NoError(t, addAction(&bytes.Buffer{}, hostsFile, []string{"127.0.0.1"}, scan.Host{Groups: []string{"web"}, Tags: []string{"prod"}}))
+	assert.NoError(t, addAction(&bytes.Buffer{}, hostsFile, []string{"localhost"}, scan.Host{Tags: []string{"prod"}, Ports: []int{closed}}))
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, listAction(out, hostsFile, nil, []string{"prod"}))
+	assert.Equal(t, fmt.Sprintf("127.0.0.1 groups=web tags=prod\nlocalhost tags=prod ports=%d\n", closed), out.String())
+
+	cfg := scanConfig{hostsFile: hostsFile, ports: []int{22}, proto: "tcp", format: outputText}
+	t.Run("ports of the hosts file", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, scanAction(context.Background(), out, cfg))
+		assert.Equal(t, fmt.Sprintf("127.0.0.1: \n\t%d: open\n\nlocalhost: \n\t%d: closed\n\n", open, closed), out.String())
+	})
+
+	t.Run("group", func(t *testing.T) {
+		cfg := cfg
+		cfg.groups = []string{"web"}
+		out := &bytes.Buffer{}
+		assert.NoError(t, scanAction(context.Background(), out, cfg))
+		assert.Equal(t, fmt.Sprintf("127.0.0.1: \n\t%d: open\n\n", open), out.String())
+
+		cfg.groups = []string{"mail"}
+		assert.Error(t, scanAction(context.Background(), out, cfg))
+	})
+
+	t.Run("forced ports", func(t *testing.T) {
+		cfg := cfg
+		cfg.ports = []int{closed}
+		cfg.forcePorts = true
+		cfg.tags = []string{"prod"}
+		out := &bytes.Buffer{}
+		assert.NoError(t, scanAction(context.Background(), out, cfg))
+		assert.Equal(t, fmt.Sprintf("127.0.0.1: \n\t%d: closed\n\nlocalhost: \n\t%d: closed\n\n", closed, closed), out.String())
+	})
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDaemon(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	hostsFile := filepath.Join(t.TempDir(), "pScan.hosts")
+	assert.NoError(t, addAction(&bytes.Buffer{}, hostsFile, []string{"127.0.0.1"}, scan.Host{}))
+
+	webhook := make(chan []event, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string][]event{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		webhook <- body["events"]
+	}))
+	defer hook.Close()
+
+	out, log := &syncBuffer{}, &syncBuffer{}
+	historyDir := t.TempDir()
+	cfg := daemonConfig{
+		scan:     scanConfig{hostsFile: hostsFile, ports: []int{port}, proto: "tcp", historyDir: historyDir, keep: 2},
+		schedule: interval(20 * time.Millisecond),
+		webhook:  hook.URL,
+		log:      log,
+	}
+	d := newDaemon(out, cfg)
+	srv := httptest.NewServer(d.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/results")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.run(ctx) }()
+
+	// the port closes between two scans
+	assert.Eventually(t, func() bool {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+		return d.scans >= 2
+	}, time.Second, 5*time.Millisecond)
+	ln.Close()
+
+	var events []event
+	select {
+	case events = <-webhook:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no events posted to the webhook")
+	}
+	cancel()
+	assert.NoError(t, <-done)
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, event{Time: events[0].Time, Type: eventPortClosed, Host: "127.0.0.1", Port: port, From: "open", To: "closed"}, events[0])
+	line := fmt.Sprintf(`"type":"port_closed","host":"127.0.0.1","port":%d,"from":"open","to":"closed"}`, port)
+	assert.Contains(t, out.String(), line)
+	assert.Contains(t, log.String(), line)
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+
+	resp, err = http.Get(srv.URL + "/results")
+	assert.NoError(t, err)
+	report := scan.Report{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	assert.Equal(t, []scan.PortState{{Port: port}}, report.Results[0].PortStates)
+
+	resp, err = http.Get(srv.URL + "/status")
+	assert.NoError(t, err)
+	status := daemonStatus{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	resp.Body.Close()
+	assert.GreaterOrEqual(t, status.Scans, 3)
+	assert.NotNil(t, status.LastScan)
+
+	runs, err := scan.NewStore(historyDir).List()
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	t.Run("history failure", func(t *testing.T) {
+		cfg := cfg
+		cfg.scan.historyDir = hostsFile
+		out := &syncBuffer{}
+		d := newDaemon(out, cfg)
+		d.scan(context.Background())
+		assert.NotNil(t, d.latest)
+		assert.Equal(t, 1, d.scans)
+		assert.Empty(t, d.lastErr)
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		cfg := cfg
+		cfg.schedule = interval(time.Hour)
+		cfg.listen = "127.0.0.1:0"
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		assert.NoError(t, daemonAction(ctx, &bytes.Buffer{}, cfg))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestCron(t *testing.T) {
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.DateTime, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	testCases := []struct {
+		expr, from, next string
+	}{
+		{"* * * * *", "2024-05-14 10:00:30", "2024-05-14 10:01:00"},
+		{"*/15 * * * *", "2024-05-14 10:01:00", "2024-05-14 10:15:00"},
+		{"0 2 * * *", "2024-05-14 10:00:00", "2024-05-15 02:00:00"},
+		{"@daily", "2024-12-31 23:59:00", "2025-01-01 00:00:00"},
+		{"30 8 * * 1-5", "2024-05-17 09:00:00", "2024-05-20 08:30:00"},
+		{"0 0 * * 7", "2024-05-14 00:00:00", "2024-05-19 00:00:00"},
+		{"0 0 1,15 * *", "2024-05-02 00:00:00", "2024-05-15 00:00:00"},
+		// either day matches when both are set
+		{"0 0 13 * 5", "2024-05-14 00:00:00", "2024-05-17 00:00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"0 0 30 2 *", "2024-03-01 00:00:00", "0001-01-01 00:00:00"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			c, err := parseCron(tc.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, at(tc.next), c.next(at(tc.from)))
+		})
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, expr)
+	}
 }
@@ -4,10 +4,13 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"go-cmd-book/pScan/scan"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -15,33 +18,109 @@ import (
 
 // addCmd represents the add command
 var addCmd = &cobra.Command{
-	Use:          "add <host1> ... <hostn>",
-	Args:         cobra.MinimumNArgs(1),
-	Short:        "Add host(s) to the host file",
+	Use:   "add <host1> ... <hostn>",
+	Short: "Add host(s) to the host file",
+	Long: `Add host(s) to the host file.
+
+A host is a name, an IPv4 or IPv6 address, a CIDR block like 10.0.0.0/28
+or a range like 10.0.0.1-20 or 2001:db8::1-2001:db8::ff. Blocks and ranges
+are stored as one line and expanded when scanning.`,
 	Aliases:      []string{"a"},
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hostsFile := viper.GetString("hosts-file")
-		return addAction(os.Stdout, hostsFile, args)
+		fromFile, err := cmd.Flags().GetString("from-file")
+		if err != nil {
+			return err
+		}
+		h := scan.Host{}
+		if h.Groups, err = cmd.Flags().GetStringSlice("group"); err != nil {
+			return err
+		}
+		if h.Tags, err = cmd.Flags().GetStringSlice("tag"); err != nil {
+			return err
+		}
+		ports, err := cmd.Flags().GetStringSlice("ports")
+		if err != nil {
+			return err
+		}
+		if len(ports) > 0 {
+			if h.Ports, err = scan.ParsePorts(ports...); err != nil {
+				return err
+			}
+		}
+		if fromFile != "" {
+			if err := importAction(os.Stdout, hostsFile, fromFile, h); err != nil {
+				return err
+			}
+		} else if len(args) == 0 {
+			return errors.New("requires at least 1 host or --from-file")
+		}
+		if len(args) == 0 {
+			return nil
+		}
+		return addAction(os.Stdout, hostsFile, args, h)
 	},
 }
 
-func addAction(out io.Writer, hostsFile string, args []string) error {
-	hl := &scan.HostList{}
-	if err := hl.Load(hostsFile); err != nil {
+// addAction adds the hosts of args with the groups, tags and ports of h.
+func addAction(out io.Writer, hostsFile string, args []string, h scan.Host) error {
+	inv, err := loadInventory(hostsFile)
+	if err != nil {
 		return err
 	}
 	for _, host := range args {
-		if err := hl.Add(host); err != nil {
+		h.Host = host
+		if err := inv.Add(h); err != nil {
 			return err
 		}
 		fmt.Fprintln(out, "Added host:", host)
 	}
-	return hl.Save(hostsFile)
+	return inv.Save(hostsFile)
+}
+
+// importAction adds the hosts of file, one per line. Blank lines and lines
+// starting with # are ignored, hosts already in the list are skipped.
+func importAction(out io.Writer, hostsFile, file string, h scan.Host) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	inv, err := loadInventory(hostsFile)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		host := strings.TrimSpace(scanner.Text())
+		if host == "" || strings.HasPrefix(host, "#") {
+			continue
+		}
+		h.Host = host
+		err := inv.Add(h)
+		if errors.Is(err, scan.ErrExists) {
+			fmt.Fprintln(out, "Skipped existing host:", host)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", file, line, err)
+		}
+		fmt.Fprintln(out, "Added host:", host)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return inv.Save(hostsFile)
 }
 
 func init() {
 	hostsCmd.AddCommand(addCmd)
+	addCmd.Flags().String("from-file", "", "add the hosts listed in a file, one per line")
+	addCmd.Flags().StringSliceP("group", "g", nil, "groups of the hosts")
+	addCmd.Flags().StringSliceP("tag", "t", nil, "tags of the hosts")
+	addCmd.Flags().StringSliceP("ports", "p", nil, "ports, ranges and port sets to scan on the hosts, instead of those of their groups")
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-cmd-book/pScan/scan"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	eventHostUp     = "host_up"
+	eventHostDown   = "host_down"
+	eventPortOpened = "port_opened"
+	eventPortClosed = "port_closed"
+	eventScanFailed = "scan_failed"
+)
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Scan the hosts list continuously and report changes",
+	Long: `Scan the hosts list on an interval or a cron schedule and report changes.
+
+Every change between two scans is written as a JSON event to stdout, and to
+the log file and the webhook when they are set. The webhook receives a POST
+with the events of a scan. The latest results are served over HTTP on
+/results, and the state of the daemon on /status.
+
+The first scan is compared with the latest run of the history directory,
+which keeps the --keep latest runs.
+On SIGINT or SIGTERM the running scan is cancelled and the HTTP server
+shut down.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := daemonConfig{}
+		var err error
+		if cfg.scan, err = scanFlags(cmd); err != nil {
+			return err
+		}
+
+		every, err := cmd.Flags().GetDuration("interval")
+		if err != nil {
+			return err
+		}
+		cronExpr, err := cmd.Flags().GetString("cron")
+		if err != nil {
+			return err
+		}
+		switch {
+		case cronExpr != "" && cmd.Flags().Changed("interval"):
+			return errors.New("set either --interval or --cron")
+		case cronExpr != "":
+			if cfg.schedule, err = parseCron(cronExpr); err != nil {
+				return err
+			}
+			if cfg.schedule.next(time.Now()).IsZero() {
+				return fmt.Errorf("cron expression %q never runs", cronExpr)
+			}
+		case every <= 0:
+			return fmt.Errorf("invalid interval %s, should be positive", every)
+		default:
+			cfg.schedule = interval(every)
+		}
+
+		if cfg.scan.keep, err = cmd.Flags().GetInt("keep"); err != nil {
+			return err
+		}
+		if cfg.scan.keep < 0 {
+			return fmt.Errorf("invalid keep %d, should be 0 or more", cfg.scan.keep)
+		}
+		if cfg.listen, err = cmd.Flags().GetString("listen"); err != nil {
+			return err
+		}
+		if cfg.webhook, err = cmd.Flags().GetString("webhook"); err != nil {
+			return err
+		}
+		logFile, err := cmd.Flags().GetString("log-file")
+		if err != nil {
+			return err
+		}
+		if logFile != "" {
+			f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			cfg.log = f
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return daemonAction(ctx, os.Stdout, cfg)
+	},
+}
+
+type daemonConfig struct {
+	scan     scanConfig
+	schedule schedule
+	// listen is the address of the HTTP server, none when empty.
+	listen  string
+	webhook string
+	log     io.Writer
+}
+
+// event is a change found by a scan, or a failed scan.
+type event struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"`
+	Host  string    `json:"host,omitempty"`
+	Port  int       `json:"port,omitempty"`
+	From  string    `json:"from,omitempty"`
+	To    string    `json:"to,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+func changeEvents(t time.Time, c scan.Changes) []event {
+	events := []event{}
+	for _, h := range c.HostsUp {
+		events = append(events, event{Time: t, Type: eventHostUp, Host: h})
+	}
+	for _, h := range c.HostsDown {
+		events = append(events, event{Time: t, Type: eventHostDown, Host: h})
+	}
+	for _, p := range c.Opened {
+		events = append(events, event{Time: t, Type: eventPortOpened, Host: p.Host, Port: p.Port, From: p.From, To: p.To})
+	}
+	for _, p := range c.Closed {
+		events = append(events, event{Time: t, Type: eventPortClosed, Host: p.Host, Port: p.Port, From: p.From, To: p.To})
+	}
+	return events
+}
+
+// daemon keeps the latest scan, read by the HTTP handlers while the scan
+// loop replaces it.
+type daemon struct {
+	cfg    daemonConfig
+	out    io.Writer
+	client *http.Client
+
+	mu      sync.RWMutex
+	latest  *scan.Report
+	scans   int
+	lastErr string
+	nextRun time.Time
+}
+
+func newDaemon(out io.Writer, cfg daemonConfig) *daemon {
+	return &daemon{cfg: cfg, out: out, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// daemonAction scans until ctx is done, serving the results on cfg.listen.
+func daemonAction(ctx context.Context, out io.Writer, cfg daemonConfig) error {
+	d := newDaemon(out, cfg)
+	if cfg.listen == "" {
+		return d.run(ctx)
+	}
+
+	ln, err := net.Listen("tcp", cfg.listen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: d.handler(), ReadHeaderTimeout: 5 * time.Second}
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Serve(ln) }()
+
+	runErr := d.run(ctx)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return runErr
+}
+
+// run scans right away and then on the schedule, one scan at a time. A
+// scan that overruns delays the next one instead of overlapping it.
+func (d *daemon) run(ctx context.Context) error {
+	if d.cfg.scan.historyDir != "" {
+		store := scan.NewStore(d.cfg.scan.historyDir)
+		if id, err := store.Resolve("latest"); err == nil {
+			if run, err := store.Load(id); err == nil && run.Protocol == d.cfg.scan.proto {
+				d.mu.Lock()
+				d.latest = &run.Report
+				d.mu.Unlock()
+			}
+		}
+	}
+
+	for {
+		started := time.Now()
+		d.scan(ctx)
+		next := d.cfg.schedule.next(started)
+		d.mu.Lock()
+		d.nextRun = next
+		d.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+func (d *daemon) scan(ctx context.Context) {
+	report, err := runScan(ctx, d.cfg.scan)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		d.mu.Lock()
+		d.lastErr = err.Error()
+		d.mu.Unlock()
+		d.emit(ctx, []event{{Time: time.Now(), Type: eventScanFailed, Error: err.Error()}})
+		return
+	}
+
+	d.mu.Lock()
+	previous := d.latest
+	d.latest = &report
+	d.scans++
+	d.lastErr = ""
+	d.mu.Unlock()
+
+	if previous != nil {
+		d.emit(ctx, changeEvents(report.Finished, scan.Diff(*previous, report)))
+	}
+}
+
+// emit writes events as JSON lines to the output and the log, and posts
+// them to the webhook. Failures are reported on stderr and do not stop the
+// daemon.
+func (d *daemon) emit(ctx context.Context, events []event) {
+	if len(events) == 0 {
+		return
+	}
+	lines := &bytes.Buffer{}
+	enc := json.NewEncoder(lines)
+	for _, e := range events {
+		enc.Encode(e)
+	}
+	d.out.Write(lines.Bytes())
+	if d.cfg.log != nil {
+		if _, err := d.cfg.log.Write(lines.Bytes()); err != nil {
+			fmt.Fprintln(os.Stderr, "Writing events to the log:", err)
+		}
+	}
+	if d.cfg.webhook != "" {
+		if err := d.post(ctx, events); err != nil {
+			fmt.Fprintln(os.Stderr, "Sending events to the webhook:", err)
+		}
+	}
+}
+
+func (d *daemon) post(ctx context.Context, events []event) error {
+	body, err := json.Marshal(map[string][]event{"events": events})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+type daemonStatus struct {
+	Scans     int        `json:"scans"`
+	LastScan  *time.Time `json:"last_scan,omitempty"`
+	NextScan  time.Time  `json:"next_scan"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+func (d *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /results", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.RLock()
+		latest := d.latest
+		d.mu.RUnlock()
+		if latest == nil {
+			http.Error(w, "no scan finished yet", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, latest)
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.RLock()
+		status := daemonStatus{Scans: d.scans, NextScan: d.nextRun, LastError: d.lastErr}
+		if d.latest != nil {
+			status.LastScan = &d.latest.Finished
+		}
+		d.mu.RUnlock()
+		writeJSON(w, status)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	addScanFlags(daemonCmd)
+	daemonCmd.Flags().Duration("interval", time.Hour, "time between the start of two scans")
+	daemonCmd.Flags().String("cron", "", "cron expression of the scans, like \"0 2 * * *\", instead of --interval")
+	daemonCmd.Flags().String("listen", "127.0.0.1:8457", "address of the HTTP server, empty to disable it")
+	daemonCmd.Flags().String("log-file", "", "file the events are appended to")
+	daemonCmd.Flags().String("webhook", "", "URL the events are posted to")
+	daemonCmd.Flags().Int("keep", 100, "number of runs kept in the history directory, 0 keeps them all")
+}
@@ -5,7 +5,6 @@ package cmd
 
 import (
 	"fmt"
-	"go-cmd-book/pScan/scan"
 	"io"
 	"os"
 
@@ -28,18 +27,18 @@ var deleteCmd = &cobra.Command{
 }
 
 func deleteAction(out io.Writer, hostsFile string, args []string) error {
-	hl := &scan.HostList{}
-	if err := hl.Load(hostsFile); err != nil {
+	inv, err := loadInventory(hostsFile)
+	if err != nil {
 		return err
 	}
 
 	for _, host := range args {
-		if err := hl.Remove(host); err != nil {
+		if err := inv.Remove(host); err != nil {
 			return err
 		}
 		fmt.Fprintln(out, "Host deleted:", host)
 	}
-	return hl.Save(hostsFile)
+	return inv.Save(hostsFile)
 }
 func init() {
 	hostsCmd.AddCommand(deleteCmd)
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-cmd-book/pScan/scan"
+	"io"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// exitChanges is the exit status of diff when the runs differ, so alerts
+// can tell changes from errors.
+const exitChanges = 3
+
+var errChanges = errors.New("changes found")
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <run1> <run2>",
+	Short: "Show what changed between two scan runs",
+	Long: `Show the hosts that appeared or disappeared and the ports that were
+opened or closed between two scan runs.
+
+A run is an ID from the history command, latest or previous. diff exits
+with status 3 when it finds changes.`,
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		if format != outputText && format != outputJSON {
+			return fmt.Errorf("invalid output, should be text/json %s", format)
+		}
+		err = diffAction(os.Stdout, viper.GetString("history-dir"), args[0], args[1], format)
+		if err != nil && !errors.Is(err, errChanges) {
+			cmd.PrintErrln("Error:", err)
+		}
+		return err
+	},
+}
+
+// diffAction writes the changes from the run ref1 to ref2. It returns
+// errChanges when there are some.
+func diffAction(out io.Writer, historyDir, ref1, ref2, format string) error {
+	store := scan.NewStore(historyDir)
+	var runs [2]scan.StoredRun
+	for i, ref := range []string{ref1, ref2} {
+		id, err := store.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		if runs[i], err = store.Load(id); err != nil {
+			return err
+		}
+	}
+	from, to := runs[0], runs[1]
+	if from.Protocol != to.Protocol {
+		return fmt.Errorf("runs %d and %d scanned different protocols, %s and %s", from.ID, to.ID, from.Protocol, to.Protocol)
+	}
+
+	changes := scan.Diff(from.Report, to.Report)
+	if err := writeChanges(out, format, from.ID, to.ID, changes); err != nil {
+		return err
+	}
+	if !changes.Empty() {
+		return errChanges
+	}
+	return nil
+}
+
+func writeChanges(out io.Writer, format string, from, to int, c scan.Changes) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			From int `json:"from"`
+			To   int `json:"to"`
+			scan.Changes
+		}{from, to, c})
+	}
+
+	if c.Empty() {
+		_, err := fmt.Fprintf(out, "No changes between runs %d and %d\n", from, to)
+		return err
+	}
+	message := fmt.Sprintf("Changes between runs %d and %d:\n", from, to)
+	for _, h := range c.HostsUp {
+		message += fmt.Sprintf("\t+ host %s\n", h)
+	}
+	for _, h := range c.HostsDown {
+		message += fmt.Sprintf("\t- host %s\n", h)
+	}
+	for _, p := range c.Opened {
+		message += fmt.Sprintf("\t+ %s %s -> %s\n", net.JoinHostPort(p.Host, strconv.Itoa(p.Port)), p.From, p.To)
+	}
+	for _, p := range c.Closed {
+		message += fmt.Sprintf("\t- %s %s -> %s\n", net.JoinHostPort(p.Host, strconv.Itoa(p.Port)), p.From, p.To)
+	}
+	_, err := io.WriteString(out, message)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringP("output", "o", outputText, "output format: text or json")
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"go-cmd-book/pScan/scan"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:          "history",
+	Short:        "List the saved scan runs",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return historyAction(os.Stdout, viper.GetString("history-dir"))
+	},
+}
+
+func historyAction(out io.Writer, historyDir string) error {
+	runs, err := scan.NewStore(historyDir).List()
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		_, err := fmt.Fprintln(out, "No runs saved in", historyDir)
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 3, 2, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tDURATION\tPROTOCOL\tHOSTS\tOPEN")
+	for _, run := range runs {
+		hosts, open := 0, 0
+		for _, r := range run.Results {
+			if r.NotFound {
+				continue
+			}
+			hosts++
+			for _, p := range r.PortStates {
+				if p.State == scan.Open {
+					open++
+				}
+			}
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\n", run.ID, run.Started.Local().Format(time.DateTime),
+			run.Finished.Sub(run.Started).Round(time.Millisecond), run.Protocol, hosts, open)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
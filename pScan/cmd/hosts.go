@@ -4,6 +4,10 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
+	"go-cmd-book/pScan/scan"
+	"os"
+
 	"github.com/spf13/cobra"
 )
 
@@ -15,7 +19,33 @@ var hostsCmd = &cobra.Command{
 
 Add hosts with the add command
 Delete hosts with the delete command
-List hosts with the list command`,
+List hosts with the list command
+
+The hosts file is YAML, or JSON when its name ends with .json. Hosts can
+belong to groups and have tags, and set the ports to scan on them. Groups
+set the ports of their hosts that have none:
+
+  groups:
+    web:
+      ports: [80, 443]
+  hosts:
+    - host: 10.0.0.0/28
+      groups: [web]
+      tags: [prod]
+    - host: db.example.com
+      ports: [5432]
+
+A hosts file with one host per line is migrated to this format when it is
+first loaded, the original is kept with a .bak suffix.`,
+}
+
+// loadInventory reads the hosts file, reporting when it was migrated.
+func loadInventory(hostsFile string) (*scan.Inventory, error) {
+	inv, err := scan.LoadInventory(hostsFile)
+	if err == nil && inv.Migrated {
+		fmt.Fprintf(os.Stderr, "Migrated %s to the structured format, the original is in %s.bak\n", hostsFile, hostsFile)
+	}
+	return inv, err
 }
 
 func init() {
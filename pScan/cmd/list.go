@@ -5,9 +5,10 @@ package cmd
 
 import (
 	"fmt"
-	"go-cmd-book/pScan/scan"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -20,25 +21,54 @@ var listCmd = &cobra.Command{
 	Aliases: []string{"l"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hostsFile := viper.GetString("hosts-file")
-		return listAction(os.Stdout, hostsFile)
+		groups, err := cmd.Flags().GetStringSlice("group")
+		if err != nil {
+			return err
+		}
+		tags, err := cmd.Flags().GetStringSlice("tag")
+		if err != nil {
+			return err
+		}
+		return listAction(os.Stdout, hostsFile, groups, tags)
 	},
 }
 
-func listAction(out io.Writer, hostsFile string) error {
-	hl := &scan.HostList{}
-	if err := hl.Load(hostsFile); err != nil {
+// listAction writes the hosts in any of groups and with all of tags.
+func listAction(out io.Writer, hostsFile string, groups, tags []string) error {
+	inv, err := loadInventory(hostsFile)
+	if err != nil {
 		return err
 	}
-	for _, h := range *hl {
-		if _, err := fmt.Fprintln(out, h); err != nil {
+	for _, h := range inv.Select(groups, tags) {
+		line := []string{h.Host}
+		if len(h.Groups) > 0 {
+			line = append(line, "groups="+strings.Join(h.Groups, ","))
+		}
+		if len(h.Tags) > 0 {
+			line = append(line, "tags="+strings.Join(h.Tags, ","))
+		}
+		if len(h.Ports) > 0 {
+			line = append(line, "ports="+joinPorts(h.Ports))
+		}
+		if _, err := fmt.Fprintln(out, strings.Join(line, " ")); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func joinPorts(ports []int) string {
+	s := make([]string, 0, len(ports))
+	for _, p := range ports {
+		s = append(s, strconv.Itoa(p))
+	}
+	return strings.Join(s, ",")
+}
+
 func init() {
 	hostsCmd.AddCommand(listCmd)
+	listCmd.Flags().StringSliceP("group", "g", nil, "only list the hosts of these groups")
+	listCmd.Flags().StringSliceP("tag", "t", nil, "only list the hosts with these tags")
 
 	// Here you will define your flags and configuration settings.
 
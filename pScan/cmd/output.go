@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"go-cmd-book/pScan/scan"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputCSV  = "csv"
+	outputXML  = "xml"
+)
+
+func validateOutput(format string) error {
+	switch format {
+	case outputText, outputJSON, outputCSV, outputXML:
+		return nil
+	}
+	return fmt.Errorf("invalid output, should be text/json/csv/xml %s", format)
+}
+
+func writeReport(out io.Writer, format string, report scan.Report) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case outputCSV:
+		return writeCSV(out, report)
+	case outputXML:
+		return writeXML(out, report)
+	}
+	return printResults(out, report.Results)
+}
+
+func printResults(out io.Writer, results []scan.Results) error {
+	for _, r := range results {
+		message := ""
+		message += fmt.Sprintf("%s: ", r.Host)
+		if r.NotFound {
+			message += "host not found\n"
+		} else {
+			message += "\n"
+		}
+		for _, p := range r.PortStates {
+			message += fmt.Sprintf("\t%d: %s", p.Port, p.State)
+			if p.Service != "" {
+				message += fmt.Sprintf(" %s %s", p.Service, p.Version)
+			}
+			message = strings.TrimRight(message, " ") + "\n"
+		}
+
+		_, err := fmt.Fprintln(out, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeCSV(out io.Writer, report scan.Report) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"host", "protocol", "port", "state", "service", "version", "banner"})
+	for _, r := range report.Results {
+		if r.NotFound {
+			w.Write([]string{r.Host, report.Protocol, "", "not found", "", "", ""})
+		}
+		for _, p := range r.PortStates {
+			w.Write([]string{r.Host, report.Protocol, strconv.Itoa(p.Port), p.State.String(), p.Service, p.Version, p.Banner})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// The nmap XML types only have the elements and attributes pScan can fill,
+// see https://nmap.org/book/nmap-dtd.html.
+type nmapRun struct {
+	XMLName  xml.Name     `xml:"nmaprun"`
+	Scanner  string       `xml:"scanner,attr"`
+	Start    int64        `xml:"start,attr"`
+	StartStr string       `xml:"startstr,attr"`
+	Version  string       `xml:"version,attr"`
+	XMLOut   string       `xml:"xmloutputversion,attr"`
+	ScanInfo nmapScanInfo `xml:"scaninfo"`
+	Hosts    []nmapHost   `xml:"host"`
+	RunStats nmapRunStats `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapHost struct {
+	Status    nmapStatus     `xml:"status"`
+	Addresses []nmapAddress  `xml:"address"`
+	Hostnames []nmapHostname `xml:"hostnames>hostname"`
+	Ports     []nmapPort     `xml:"ports>port"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapStatus   `xml:"state"`
+	Service  *nmapService `xml:"service"`
+	Scripts  []nmapScript `xml:"script"`
+}
+
+type nmapService struct {
+	Name    string `xml:"name,attr"`
+	Product string `xml:"product,attr,omitempty"`
+	Method  string `xml:"method,attr"`
+	Conf    int    `xml:"conf,attr"`
+}
+
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished `xml:"finished"`
+	Hosts    nmapHosts    `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64   `xml:"time,attr"`
+	TimeStr string  `xml:"timestr,attr"`
+	Elapsed float64 `xml:"elapsed,attr"`
+	Exit    string  `xml:"exit,attr"`
+}
+
+type nmapHosts struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// portReason is the nmap reason of a port state.
+func portReason(proto string, p scan.PortState) string {
+	switch {
+	case p.State == scan.Filtered || p.State == scan.OpenFiltered:
+		return "no-response"
+	case p.State == scan.Unreachable:
+		return "host-unreach"
+	case p.State == scan.Open && proto == "udp":
+		return "udp-response"
+	case p.State == scan.Open:
+		return "syn-ack"
+	case proto == "udp":
+		return "port-unreach"
+	}
+	return "conn-refused"
+}
+
+func writeXML(out io.Writer, report scan.Report) error {
+	run := nmapRun{
+		Scanner:  "pScan",
+		Start:    report.Started.Unix(),
+		StartStr: report.Started.Format("Mon Jan 2 15:04:05 2006"),
+		Version:  rootCmd.Version,
+		XMLOut:   "1.05",
+		ScanInfo: nmapScanInfo{Type: "connect", Protocol: report.Protocol},
+		RunStats: nmapRunStats{Finished: nmapFinished{
+			Time:    report.Finished.Unix(),
+			TimeStr: report.Finished.Format("Mon Jan 2 15:04:05 2006"),
+			Elapsed: report.Finished.Sub(report.Started).Seconds(),
+			Exit:    "success",
+		}},
+	}
+	if report.Protocol == "udp" {
+		run.ScanInfo.Type = "udp"
+	}
+
+	for _, r := range report.Results {
+		h := nmapHost{Status: nmapStatus{State: "up", Reason: "user-set"}}
+		if r.NotFound {
+			h.Status = nmapStatus{State: "down", Reason: "no-resolve"}
+			run.RunStats.Hosts.Down++
+		} else {
+			run.RunStats.Hosts.Up++
+		}
+		addr := r.Address
+		if net.ParseIP(r.Host) != nil {
+			addr = r.Host
+		} else {
+			h.Hostnames = append(h.Hostnames, nmapHostname{Name: r.Host, Type: "user"})
+		}
+		if ip := net.ParseIP(addr); ip != nil {
+			addrType := "ipv6"
+			if ip.To4() != nil {
+				addrType = "ipv4"
+			}
+			h.Addresses = append(h.Addresses, nmapAddress{Addr: addr, AddrType: addrType})
+		}
+
+		for _, p := range r.PortStates {
+			port := nmapPort{
+				Protocol: report.Protocol,
+				PortID:   p.Port,
+				State:    nmapStatus{State: p.State.String(), Reason: portReason(report.Protocol, p)},
+			}
+			if p.Service != "" {
+				port.Service = &nmapService{Name: p.Service, Product: p.Version, Method: "probed", Conf: 10}
+			}
+			if p.Banner != "" {
+				port.Scripts = append(port.Scripts, nmapScript{ID: "banner", Output: p.Banner})
+			}
+			h.Ports = append(h.Ports, port)
+		}
+		run.Hosts = append(run.Hosts, h)
+	}
+	services := make([]string, 0, len(report.Ports))
+	for _, p := range report.Ports {
+		services = append(services, strconv.Itoa(p))
+	}
+	run.ScanInfo.NumServices = len(services)
+	run.ScanInfo.Services = strings.Join(services, ",")
+	run.RunStats.Hosts.Total = len(report.Results)
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(run); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
@@ -4,12 +4,14 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"go-cmd-book/pScan/scan"
 	"io"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -20,101 +22,282 @@ var scanCmd = &cobra.Command{
 	Use:   "scan",
 	Short: "Run the scan for the hosts list",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		hostsFile := viper.GetString("hosts-file")
-
-		ports, err := cmd.Flags().GetIntSlice("ports")
+		cfg, err := scanFlags(cmd)
 		if err != nil {
 			return err
 		}
-		portRange, err := cmd.Flags().GetString("port-range")
-		if err != nil {
-			return err
-		}
-		startPort, endPort, err := parsePortRange(portRange)
+
+		cfg.filterClosed, err = cmd.Flags().GetBool("filter-closed")
 		if err != nil {
 			return err
 		}
 
-		for i := startPort; i <= endPort; i++ {
-			ports = append(ports, i)
-		}
-
-		proto, err := cmd.Flags().GetString("protocol")
+		cfg.format, err = cmd.Flags().GetString("output")
 		if err != nil {
 			return err
 		}
-
-		if proto != "tcp" && proto != "udp" {
-			return fmt.Errorf("invalid protocol, should be tcp/udp %s", proto)
+		if err := validateOutput(cfg.format); err != nil {
+			return err
 		}
 
-		filterClosed, err := cmd.Flags().GetBool("filter-closed")
+		out := io.Writer(os.Stdout)
+		outputFile, err := cmd.Flags().GetString("output-file")
 		if err != nil {
 			return err
 		}
+		if outputFile != "" {
+			f, err := os.Create(outputFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
 
-		return scanAction(os.Stdout, hostsFile, ports, proto, filterClosed)
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		return scanAction(ctx, out, cfg)
 	},
 }
 
-func parsePortRange(portRange string) (int, int, error) {
-	if portRange == "" {
-		return 0, 0, nil
+// scanFlags reads the flags added by addScanFlags.
+func scanFlags(cmd *cobra.Command) (scanConfig, error) {
+	cfg := scanConfig{
+		hostsFile:  viper.GetString("hosts-file"),
+		historyDir: viper.GetString("history-dir"),
+	}
+
+	var err error
+	cfg.proto, err = cmd.Flags().GetString("protocol")
+	if err != nil {
+		return cfg, err
+	}
+
+	if cfg.proto != "tcp" && cfg.proto != "udp" {
+		return cfg, fmt.Errorf("invalid protocol, should be tcp/udp %s", cfg.proto)
+	}
+
+	// explicit ports replace those of the hosts file
+	cfg.forcePorts = cmd.Flags().Changed("ports") || cmd.Flags().Changed("port-range") || cmd.Flags().Changed("top")
+	if cfg.ports, err = scanPorts(cmd, cfg.proto); err != nil {
+		return cfg, err
+	}
+
+	cfg.groups, err = cmd.Flags().GetStringSlice("group")
+	if err != nil {
+		return cfg, err
 	}
-	parts := strings.Split(portRange, "-")
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("invalid port range: %s", portRange)
+	cfg.tags, err = cmd.Flags().GetStringSlice("tag")
+	if err != nil {
+		return cfg, err
 	}
-	lowerBound, err := strconv.Atoi(parts[0])
+
+	cfg.opts.Workers, err = cmd.Flags().GetInt("workers")
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid port range: %s", portRange)
+		return cfg, err
+	}
+	if cfg.opts.Workers < 1 {
+		return cfg, fmt.Errorf("invalid workers %d, should be at least 1", cfg.opts.Workers)
 	}
-	upperBound, err := strconv.Atoi(parts[1])
+
+	cfg.opts.Banners, err = cmd.Flags().GetBool("banners")
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid port range: %s", portRange)
+		return cfg, err
+	}
+
+	// the pacing and timeouts can also be set in the config file or the
+	// environment
+	for _, key := range []string{"rate", "host-workers", "jitter", "random-order", "timeout", "retries"} {
+		if err := viper.BindPFlag(key, cmd.Flags().Lookup(key)); err != nil {
+			return cfg, err
+		}
+	}
+	cfg.opts.Rate = viper.GetFloat64("rate")
+	if cfg.opts.Rate < 0 {
+		return cfg, fmt.Errorf("invalid rate %g, should be positive or 0", cfg.opts.Rate)
 	}
-	return lowerBound, upperBound, nil
+	cfg.opts.HostWorkers = viper.GetInt("host-workers")
+	if cfg.opts.HostWorkers < 0 {
+		return cfg, fmt.Errorf("invalid host workers %d, should be positive or 0", cfg.opts.HostWorkers)
+	}
+	cfg.opts.Jitter = viper.GetDuration("jitter")
+	if cfg.opts.Jitter < 0 {
+		return cfg, fmt.Errorf("invalid jitter %s, should be positive or 0", cfg.opts.Jitter)
+	}
+	cfg.opts.RandomOrder = viper.GetBool("random-order")
+	cfg.opts.Timeout = viper.GetDuration("timeout")
+	if cfg.opts.Timeout <= 0 {
+		return cfg, fmt.Errorf("invalid timeout %s, should be positive", cfg.opts.Timeout)
+	}
+	cfg.opts.Retries = viper.GetInt("retries")
+	if cfg.opts.Retries < 0 {
+		return cfg, fmt.Errorf("invalid retries %d, should be positive or 0", cfg.opts.Retries)
+	}
+	return cfg, nil
 }
 
-func scanAction(out io.Writer, hostsFile string, ports []int, proto string, filterClosed bool) error {
-	hl := &scan.HostList{}
-	if err := hl.Load(hostsFile); err != nil {
-		return err
+// scanPorts returns the ports of --ports, --port-range and --top. The
+// default ports are only scanned when none of them is set.
+func scanPorts(cmd *cobra.Command, proto string) ([]int, error) {
+	specs, err := cmd.Flags().GetStringSlice("ports")
+	if err != nil {
+		return nil, err
+	}
+	portRange, err := cmd.Flags().GetString("port-range")
+	if err != nil {
+		return nil, err
+	}
+	top, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		return nil, err
 	}
 
-	return printResults(out, scan.Run(hl, ports, proto), filterClosed)
+	if !cmd.Flags().Changed("ports") && (portRange != "" || top > 0) {
+		specs = nil
+	}
+	if portRange != "" {
+		if !strings.Contains(portRange, "-") {
+			return nil, fmt.Errorf("invalid port range %s, should be like 1-1024", portRange)
+		}
+		specs = append(specs, portRange)
+	}
+	ports, err := scan.ParsePorts(specs...)
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.Flags().Changed("top") {
+		return ports, nil
+	}
+	topPorts, err := scan.TopPorts(proto, top)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int]bool, len(ports))
+	for _, p := range ports {
+		seen[p] = true
+	}
+	for _, p := range topPorts {
+		if !seen[p] {
+			seen[p] = true
+			ports = append(ports, p)
+		}
+	}
+	return ports, nil
+}
+
+type scanConfig struct {
+	hostsFile string
+	// ports are scanned on the hosts that set none, or on all of them
+	// with forcePorts.
+	ports        []int
+	forcePorts   bool
+	groups       []string
+	tags         []string
+	proto        string
+	filterClosed bool
+	format       string
+	// historyDir is where the run is saved, nowhere when empty.
+	historyDir string
+	// keep is the number of runs kept in historyDir, all when 0.
+	keep int
+	opts scan.Options
+}
+
+func scanAction(ctx context.Context, out io.Writer, cfg scanConfig) error {
+	report, err := runScan(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.filterClosed {
+		report.Results = filterClosedPorts(report.Results)
+	}
+	return writeReport(out, cfg.format, report)
 }
 
-func printResults(out io.Writer, results []scan.Results, filterClosed bool) error {
-	for _, r := range results {
-		message := ""
-		message += fmt.Sprintf("%s: ", r.Host)
-		if r.NotFound {
-			message += "host not found \n\n"
-			continue
+// runScan scans the hosts selected by cfg and saves the run. A run that
+// cannot be saved is reported on stderr, its results are still returned.
+func runScan(ctx context.Context, cfg scanConfig) (scan.Report, error) {
+	inv, err := loadInventory(cfg.hostsFile)
+	if err != nil {
+		return scan.Report{}, err
+	}
+	hosts := inv.Select(cfg.groups, cfg.tags)
+	if len(hosts) == 0 && (len(cfg.groups) > 0 || len(cfg.tags) > 0) {
+		return scan.Report{}, fmt.Errorf("no hosts in groups %v with tags %v", cfg.groups, cfg.tags)
+	}
+
+	report := scan.Report{Protocol: cfg.proto, Ports: []int{}}
+	targets := make([]scan.Target, 0, len(hosts))
+	seen := map[int]bool{}
+	for _, h := range hosts {
+		t := scan.Target{Host: h.Host, Ports: cfg.ports}
+		if !cfg.forcePorts {
+			t.Ports = inv.Ports(h, cfg.ports)
 		}
-		message += "\n"
-		for _, p := range r.PortStates {
-			if filterClosed && !bool(p.Open) {
-				continue
+		for _, p := range t.Ports {
+			if !seen[p] {
+				seen[p] = true
+				report.Ports = append(report.Ports, p)
 			}
-			message += fmt.Sprintf("\t%d: %s\n", p.Port, p.Open)
 		}
+		targets = append(targets, t)
+	}
 
-		_, err := fmt.Fprintln(out, message)
-		if err != nil {
-			return nil
+	report.Started = time.Now()
+	results, err := scan.RunTargets(ctx, targets, cfg.proto, cfg.opts)
+	if err != nil {
+		return scan.Report{}, fmt.Errorf("scan interrupted: %w", err)
+	}
+	report.Finished = time.Now()
+	report.Results = results
+	if cfg.historyDir != "" {
+		store := scan.NewStore(cfg.historyDir)
+		if _, err := store.Save(report); err != nil {
+			fmt.Fprintln(os.Stderr, "Saving the run:", err)
+		} else if cfg.keep > 0 {
+			if err := store.Prune(cfg.keep); err != nil {
+				fmt.Fprintln(os.Stderr, "Removing old runs:", err)
+			}
 		}
 	}
+	return report, nil
+}
 
-	return nil
+func filterClosedPorts(results []scan.Results) []scan.Results {
+	filtered := make([]scan.Results, len(results))
+	for i, r := range results {
+		filtered[i] = scan.Results{Host: r.Host, Address: r.Address, NotFound: r.NotFound}
+		for _, p := range r.PortStates {
+			if p.State == scan.Open || p.State == scan.OpenFiltered {
+				filtered[i].PortStates = append(filtered[i].PortStates, p)
+			}
+		}
+	}
+	return filtered
+}
+
+// addScanFlags adds the flags selecting what to scan and how.
+func addScanFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceP("ports", "p", []string{"22", "80", "443"}, "ports, ranges and port sets ("+strings.Join(scan.PortSetNames(), ", ")+") to scan on the hosts that set none, or on all hosts when set, ex (web,8000-8100,9200)")
+	cmd.Flags().StringP("port-range", "r", "", "port range, ex (1-1024)")
+	cmd.Flags().Int("top", 0, "scan the N most common ports of the protocol")
+	cmd.Flags().String("protocol", "tcp", "tcp or udp proctol")
+	cmd.Flags().IntP("workers", "w", scan.DefaultWorkers, "number of ports scanned at once")
+	cmd.Flags().BoolP("banners", "b", false, "grab banners to detect services on open TCP ports")
+	cmd.Flags().StringSliceP("group", "g", nil, "only scan the hosts of these groups")
+	cmd.Flags().StringSliceP("tag", "t", nil, "only scan the hosts with these tags")
+	cmd.Flags().Float64("rate", 0, "most probes sent per second, unlimited when 0")
+	cmd.Flags().Int("host-workers", 0, "most ports scanned at once on each host, unlimited when 0")
+	cmd.Flags().Duration("jitter", 0, "longest random delay before each probe")
+	cmd.Flags().Bool("random-order", false, "scan the ports and hosts in a random order")
+	cmd.Flags().Duration("timeout", scan.DefaultTimeout, "longest time a TCP connection can take before the port is filtered")
+	cmd.Flags().Int("retries", 0, "times a filtered or unreachable port is probed again")
 }
 
 func init() {
 	rootCmd.AddCommand(scanCmd)
-	scanCmd.Flags().IntSliceP("ports", "p", []int{22, 80, 443}, "ports to scan")
-	scanCmd.Flags().StringP("port-range", "r", "", "port range, ex (1-1024)")
-	scanCmd.Flags().String("protocol", "tcp", "tcp or udp proctol")
-	scanCmd.Flags().BoolP("filter-closed", "c", false, "dont display closed ports")
-
+	addScanFlags(scanCmd)
+	scanCmd.Flags().BoolP("filter-closed", "c", false, "only display open and open|filtered ports")
+	scanCmd.Flags().StringP("output", "o", outputText, "output format: text, json, csv or xml (nmap)")
+	scanCmd.Flags().String("output-file", "", "write the results to a file instead of stdout")
 }
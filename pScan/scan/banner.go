@@ -0,0 +1,111 @@
+package scan
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultBannerTimeout is how long a banner grab waits for each read when
+// Options sets no timeout.
+const DefaultBannerTimeout = 1 * time.Second
+
+const (
+	httpProbe  = "HEAD / HTTP/1.0\r\n\r\n"
+	redisProbe = "INFO server\r\n"
+)
+
+// probes are sent to the ports of services that wait for the client to
+// speak first. Other ports get a chance to send a greeting before the HTTP
+// probe.
+var probes = map[int]string{
+	80:   httpProbe,
+	443:  httpProbe,
+	8000: httpProbe,
+	8080: httpProbe,
+	8443: httpProbe,
+	6379: redisProbe,
+}
+
+// signature identifies a service from the start of its response. version
+// has one group with the version, it is optional.
+type signature struct {
+	service string
+	match   *regexp.Regexp
+	version *regexp.Regexp
+}
+
+// signatures are tried in order, the first match wins.
+var signatures = []signature{
+	{"ssh", regexp.MustCompile(`^SSH-\d+\.\d+-`), regexp.MustCompile(`^SSH-\d+\.\d+-(\S+)`)},
+	{"http", regexp.MustCompile(`^HTTP/\d(\.\d)? \d{3}`), regexp.MustCompile(`(?im)^Server:[ \t]*([^\r\n]+)`)},
+	{"smtp", regexp.MustCompile(`^220[ -][^\r\n]*SMTP`), regexp.MustCompile(`^220[ -]\S+ E?SMTP ([^\r\n]+)`)},
+	{"ftp", regexp.MustCompile(`^220[ -][^\r\n]*FTP`), regexp.MustCompile(`^220[ -][^\r\n]*\((\S*FTP\S* [\d.]+)\)`)},
+	{"redis", regexp.MustCompile(`redis_version:|^-NOAUTH|^-DENIED`), regexp.MustCompile(`redis_version:([^\r\n]+)`)},
+	{"mysql", regexp.MustCompile(`(?s)^.{4}\x0a\d+\.\d+`), regexp.MustCompile(`(?s)^.{4}\x0a([^\x00]+)\x00`)},
+	{"pop3", regexp.MustCompile(`^\+OK`), nil},
+	{"imap", regexp.MustCompile(`^\* OK`), nil},
+}
+
+// grabBanner reads what the service on conn sends, sending a probe when it
+// expects the client to speak first, and identifies the service.
+func grabBanner(conn net.Conn, port int, timeout time.Duration) (service, version, banner string) {
+	buf := make([]byte, 4096)
+	read := func() string {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		n, _ := conn.Read(buf)
+		return string(buf[:n])
+	}
+
+	resp := ""
+	probe, ok := probes[port]
+	if !ok {
+		resp = read()
+		probe = httpProbe
+	}
+	if resp == "" {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := conn.Write([]byte(probe)); err != nil {
+			return "", "", ""
+		}
+		resp = read()
+	}
+	if resp == "" {
+		return "", "", ""
+	}
+
+	service, version = identify(resp)
+	return service, version, firstLine(resp)
+}
+
+func identify(resp string) (service, version string) {
+	for _, s := range signatures {
+		if !s.match.MatchString(resp) {
+			continue
+		}
+		if s.version != nil {
+			if m := s.version.FindStringSubmatch(resp); m != nil {
+				version = strings.TrimSpace(m[1])
+			}
+		}
+		return s.service, version
+	}
+	return "", ""
+}
+
+// firstLine returns the printable start of resp.
+func firstLine(resp string) string {
+	line, _, _ := strings.Cut(resp, "\n")
+	line = strings.Map(func(r rune) rune {
+		if unicode.IsPrint(r) {
+			return r
+		}
+		return -1
+	}, strings.ToValidUTF8(line, ""))
+	if r := []rune(line); len(r) > 128 {
+		line = string(r[:128])
+	}
+	return strings.TrimSpace(line)
+}
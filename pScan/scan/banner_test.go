@@ -0,0 +1,114 @@
+package scan_test
+
+import (
+	"bufio"
+	"context"
+	"go-cmd-book/pScan/scan"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveTCP runs handle for every connection to a local listener and returns
+// its address.
+func serveTCP(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// greet sends greeting as soon as a client connects.
+func greet(greeting string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		conn.Write([]byte(greeting))
+		conn.Read(make([]byte, 1))
+	}
+}
+
+// answer replies with resp once the client sent a request line.
+func answer(request, resp string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, request) {
+			return
+		}
+		conn.Write([]byte(resp))
+	}
+}
+
+func TestBanners(t *testing.T) {
+	testCases := []struct {
+		name     string
+		port     int
+		handle   func(conn net.Conn)
+		expected scan.PortState
+	}{
+		{"ssh", 22, greet("SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n"),
+			scan.PortState{Service: "ssh", Version: "OpenSSH_9.6p1", Banner: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13"}},
+		{"smtp", 25, greet("220 mail.example.com ESMTP Postfix (Ubuntu)\r\n"),
+			scan.PortState{Service: "smtp", Version: "Postfix (Ubuntu)", Banner: "220 mail.example.com ESMTP Postfix (Ubuntu)"}},
+		{"ftp", 21, greet("220 (vsFTPd 3.0.5)\r\n"),
+			scan.PortState{Service: "ftp", Version: "vsFTPd 3.0.5", Banner: "220 (vsFTPd 3.0.5)"}},
+		{"http", 80, answer("HEAD / HTTP/1.0", "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nServer: nginx/1.25.3\r\n\r\n"),
+			scan.PortState{Service: "http", Version: "nginx/1.25.3", Banner: "HTTP/1.1 200 OK"}},
+		{"http without greeting", 3000, answer("HEAD / HTTP/1.0", "HTTP/1.0 404 Not Found\r\n\r\n"),
+			scan.PortState{Service: "http", Banner: "HTTP/1.0 404 Not Found"}},
+		{"redis", 6379, answer("INFO server", "$120\r\n# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n"),
+			scan.PortState{Service: "redis", Version: "7.2.4", Banner: "$120"}},
+		{"unknown", 4000, greet("hello\r\n"),
+			scan.PortState{Banner: "hello"}},
+		{"silent", 5000, func(conn net.Conn) { conn.Read(make([]byte, 64)) },
+			scan.PortState{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := serveTCP(t, tc.handle)
+			// probe the well-known port, connecting to the local server
+			d := &net.Dialer{}
+			dial := func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return d.DialContext(ctx, network, addr)
+			}
+			opts := scan.Options{Dial: dial, Banners: true, BannerTimeout: 100 * time.Millisecond}
+
+			results, err := scan.RunContext(context.Background(), &scan.HostList{"127.0.0.1"}, []int{tc.port}, "tcp", opts)
+			assert.NoError(t, err)
+			tc.expected.Port = tc.port
+			tc.expected.State = scan.Open
+			assert.Equal(t, []scan.PortState{tc.expected}, results[0].PortStates)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		addr := serveTCP(t, greet("SSH-2.0-OpenSSH_9.6p1\r\n"))
+		_, port, _ := net.SplitHostPort(addr)
+		results, err := scan.RunContext(context.Background(), &scan.HostList{"127.0.0.1"}, []int{atoi(t, port)}, "tcp", scan.Options{})
+		assert.NoError(t, err)
+		assert.Equal(t, []scan.PortState{{Port: atoi(t, port), State: scan.Open}}, results[0].PortStates)
+	})
+}
+
+func atoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	assert.NoError(t, err)
+	return n
+}
@@ -0,0 +1,231 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrNoRun = errors.New("run not found")
+
+// StoredRun is a scan saved in a Store.
+type StoredRun struct {
+	ID int `json:"id"`
+	Report
+}
+
+// Store keeps every scan as a JSON file named after its run ID in a
+// directory.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) ids() ([]int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for _, e := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (s *Store) file(id int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.json", id))
+}
+
+// Save stores r as a new run.
+func (s *Store) Save(r Report) (StoredRun, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return StoredRun{}, err
+	}
+	run := StoredRun{ID: 1, Report: r}
+	if len(ids) > 0 {
+		run.ID = ids[len(ids)-1] + 1
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return StoredRun{}, err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return StoredRun{}, err
+	}
+	// O_EXCL so concurrent scans do not overwrite each other, the one that
+	// loses takes the next id
+	f, err := os.OpenFile(s.file(run.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	for errors.Is(err, os.ErrExist) {
+		run.ID++
+		f, err = os.OpenFile(s.file(run.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if err != nil {
+		return StoredRun{}, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return StoredRun{}, err
+	}
+	return run, f.Close()
+}
+
+// Load returns the run id.
+func (s *Store) Load(id int) (StoredRun, error) {
+	data, err := os.ReadFile(s.file(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return StoredRun{}, fmt.Errorf("%w: %d", ErrNoRun, id)
+	}
+	if err != nil {
+		return StoredRun{}, err
+	}
+	run := StoredRun{ID: id}
+	if err := json.Unmarshal(data, &run.Report); err != nil {
+		return StoredRun{}, fmt.Errorf("invalid run %s: %w", s.file(id), err)
+	}
+	return run, nil
+}
+
+// List returns the runs, oldest first.
+func (s *Store) List() ([]StoredRun, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]StoredRun, 0, len(ids))
+	for _, id := range ids {
+		run, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// Prune removes the runs but the keep latest ones.
+func (s *Store) Prune(keep int) error {
+	ids, err := s.ids()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids[:max(len(ids)-keep, 0)] {
+		if err := os.Remove(s.file(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve returns the ID of ref, a run ID, latest or previous, the run
+// before the latest one.
+func (s *Store) Resolve(ref string) (int, error) {
+	back := 0
+	switch ref {
+	case "latest":
+	case "previous":
+		back = 1
+	default:
+		id, err := strconv.Atoi(ref)
+		if err != nil {
+			return 0, fmt.Errorf("invalid run %q, should be an ID, latest or previous", ref)
+		}
+		return id, nil
+	}
+	ids, err := s.ids()
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) <= back {
+		return 0, fmt.Errorf("%w: %s", ErrNoRun, ref)
+	}
+	return ids[len(ids)-1-back], nil
+}
+
+// PortChange is a port that was open in one run only.
+type PortChange struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Changes are the differences between two runs.
+type Changes struct {
+	HostsUp   []string     `json:"hosts_up"`
+	HostsDown []string     `json:"hosts_down"`
+	Opened    []PortChange `json:"opened"`
+	Closed    []PortChange `json:"closed"`
+}
+
+func (c Changes) Empty() bool {
+	return len(c.HostsUp)+len(c.HostsDown)+len(c.Opened)+len(c.Closed) == 0
+}
+
+// Diff compares the run from with the later run to. A host is up when it was
+// found. Ports are compared on hosts up in both runs, those not scanned in
+// one of them are ignored.
+func Diff(from, to Report) Changes {
+	c := Changes{HostsUp: []string{}, HostsDown: []string{}, Opened: []PortChange{}, Closed: []PortChange{}}
+	up := func(r Report) map[string]Results {
+		hosts := map[string]Results{}
+		for _, res := range r.Results {
+			if !res.NotFound {
+				hosts[res.Host] = res
+			}
+		}
+		return hosts
+	}
+	before, after := up(from), up(to)
+
+	for _, res := range to.Results {
+		prev, ok := before[res.Host]
+		if res.NotFound {
+			continue
+		}
+		if !ok {
+			c.HostsUp = append(c.HostsUp, res.Host)
+			continue
+		}
+		states := make(map[int]PortState, len(prev.PortStates))
+		for _, q := range prev.PortStates {
+			states[q.Port] = q
+		}
+		for _, p := range res.PortStates {
+			q, ok := states[p.Port]
+			if !ok {
+				continue
+			}
+			change := PortChange{Host: res.Host, Port: p.Port, From: q.State.String(), To: p.State.String()}
+			switch {
+			case p.State == Open && q.State != Open:
+				c.Opened = append(c.Opened, change)
+			case p.State != Open && q.State == Open:
+				c.Closed = append(c.Closed, change)
+			}
+		}
+	}
+	for _, res := range from.Results {
+		if _, ok := after[res.Host]; !ok && !res.NotFound {
+			c.HostsDown = append(c.HostsDown, res.Host)
+		}
+	}
+	return c
+}
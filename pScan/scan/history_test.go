@@ -0,0 +1,113 @@
+package scan_test
+
+import (
+	"go-cmd-book/pScan/scan"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	store := scan.NewStore(t.TempDir())
+
+	runs, err := store.List()
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+	_, err = store.Resolve("latest")
+	assert.ErrorIs(t, err, scan.ErrNoRun)
+
+	started := time.Date(2024, 5, 14, 2, 0, 0, 0, time.UTC)
+	for i, state := range []scan.State{scan.Closed, scan.Open, scan.Open} {
+		report := scan.Report{
+			Protocol: "tcp",
+			Ports:    []int{22},
+			Started:  started.Add(time.Duration(i) * 24 * time.Hour),
+			Finished: started.Add(time.Duration(i)*24*time.Hour + time.Second),
+			Results:  []scan.Results{{Host: "host1", PortStates: []scan.PortState{{Port: 22, State: state}}}},
+		}
+		run, err := store.Save(report)
+		assert.NoError(t, err)
+		assert.Equal(t, i+1, run.ID)
+	}
+
+	runs, err = store.List()
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+	assert.Equal(t, 3, runs[2].ID)
+	assert.Equal(t, started.Add(48*time.Hour), runs[2].Started)
+
+	for ref, id := range map[string]int{"latest": 3, "previous": 2, "1": 1} {
+		got, err := store.Resolve(ref)
+		assert.NoError(t, err)
+		assert.Equal(t, id, got, ref)
+	}
+	_, err = store.Resolve("yesterday")
+	assert.Error(t, err)
+
+	run, err := store.Load(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []scan.PortState{{Port: 22, State: scan.Open}}, run.Results[0].PortStates)
+	_, err = store.Load(4)
+	assert.ErrorIs(t, err, scan.ErrNoRun)
+
+	t.Run("prune", func(t *testing.T) {
+		assert.NoError(t, store.Prune(2))
+		runs, err := store.List()
+		assert.NoError(t, err)
+		assert.Len(t, runs, 2)
+		assert.Equal(t, 2, runs[0].ID)
+
+		run, err := store.Save(scan.Report{Protocol: "tcp"})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, run.ID)
+		assert.NoError(t, store.Prune(0))
+		runs, err = store.List()
+		assert.NoError(t, err)
+		assert.Empty(t, runs)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		store := scan.NewStore(t.TempDir())
+		ids := make([]int, 10)
+		wg := sync.WaitGroup{}
+		for i := range ids {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run, err := store.Save(scan.Report{Protocol: "tcp"})
+				assert.NoError(t, err)
+				ids[i] = run.ID
+			}()
+		}
+		wg.Wait()
+		assert.ElementsMatch(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, ids)
+	})
+}
+
+func TestDiff(t *testing.T) {
+	ports := func(states ...scan.PortState) []scan.PortState { return states }
+	from := scan.Report{Results: []scan.Results{
+		{Host: "stays", PortStates: ports(scan.PortState{Port: 22, State: scan.Open}, scan.PortState{Port: 80}, scan.PortState{Port: 443, State: scan.Open})},
+		{Host: "leaves", PortStates: ports(scan.PortState{Port: 22, State: scan.Open})},
+		{Host: "resolves", NotFound: true},
+		{Host: "unknown", NotFound: true},
+	}}
+	to := scan.Report{Results: []scan.Results{
+		{Host: "stays", PortStates: ports(scan.PortState{Port: 22, State: scan.Open}, scan.PortState{Port: 80, State: scan.Open}, scan.PortState{Port: 443}, scan.PortState{Port: 8080, State: scan.Open})},
+		{Host: "leaves", NotFound: true},
+		{Host: "resolves", PortStates: ports(scan.PortState{Port: 22})},
+		{Host: "unknown", NotFound: true},
+		{Host: "new"},
+	}}
+
+	assert.Equal(t, scan.Changes{
+		HostsUp:   []string{"resolves", "new"},
+		HostsDown: []string{"leaves"},
+		Opened:    []scan.PortChange{{Host: "stays", Port: 80, From: "closed", To: "open"}},
+		Closed:    []scan.PortChange{{Host: "stays", Port: 443, From: "open", To: "closed"}},
+	}, scan.Diff(from, to))
+
+	assert.True(t, scan.Diff(to, to).Empty())
+}
@@ -77,3 +77,62 @@ func TestHostList(t *testing.T) {
 
 	})
 }
+
+func TestHostTargets(t *testing.T) {
+	testCases := []struct {
+		name   string
+		host   string
+		stored string
+		hosts  []string
+	}{
+		{"name", "Host1.Example.COM.", "host1.example.com", []string{"host1.example.com"}},
+		{"ipv4", "10.0.0.1", "10.0.0.1", []string{"10.0.0.1"}},
+		{"ipv6", "[2001:DB8:0::1]", "2001:db8::1", []string{"2001:db8::1"}},
+		{"mapped ipv4", "::ffff:10.0.0.1", "10.0.0.1", []string{"10.0.0.1"}},
+		{"cidr", "10.0.0.5/30", "10.0.0.4/30", []string{"10.0.0.4", "10.0.0.5", "10.0.0.6", "10.0.0.7"}},
+		{"single ip cidr", "10.0.0.1/32", "10.0.0.1", []string{"10.0.0.1"}},
+		{"ipv6 cidr", "2001:db8::/127", "2001:db8::/127", []string{"2001:db8::", "2001:db8::1"}},
+		{"short range", "10.0.0.1-3", "10.0.0.1-10.0.0.3", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{"range", "10.0.0.255-10.0.1.1", "10.0.0.255-10.0.1.1", []string{"10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{"ipv6 range", "2001:db8::ff-2001:db8::100", "2001:db8::ff-2001:db8::100", []string{"2001:db8::ff", "2001:db8::100"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostList{}
+			assert.NoError(t, hl.Add(tc.host))
+			assert.Equal(t, scan.HostList{tc.stored}, *hl)
+			assert.Equal(t, tc.hosts, hl.Hosts())
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, host := range []string{"", "-host", "host..com", "bad host", "10.0.0.0/33", "10.0.0.5-1", "10.0.0.1-256", "10.0.0.1-::1", "2001:db8::1-ff", "10.0.0.0/8", "2001:db8::/64"} {
+			hl := &scan.HostList{}
+			assert.ErrorIs(t, hl.Add(host), scan.ErrInvalidHost, host)
+		}
+	})
+
+	t.Run("largest range", func(t *testing.T) {
+		hl := &scan.HostList{}
+		assert.NoError(t, hl.Add("10.0.0.0/16"))
+		assert.Len(t, hl.Hosts(), scan.MaxRangeSize)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		hl := &scan.HostList{}
+		assert.NoError(t, hl.Add("example.com"))
+		assert.NoError(t, hl.Add("10.0.0.0/30"))
+		assert.ErrorIs(t, hl.Add("EXAMPLE.com."), scan.ErrExists)
+		assert.ErrorIs(t, hl.Add("10.0.0.2"), scan.ErrExists)
+		assert.ErrorIs(t, hl.Add("10.0.0.1-3"), scan.ErrExists)
+		assert.ErrorIs(t, hl.Add("10.0.0.3/30"), scan.ErrExists)
+
+		// overlapping ranges are kept, their hosts are scanned once
+		assert.NoError(t, hl.Add("10.0.0.2-5"))
+		assert.Equal(t, []string{"example.com", "10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}, hl.Hosts())
+
+		assert.NoError(t, hl.Remove("Example.com"))
+		assert.NoError(t, hl.Remove("10.0.0.1/30"))
+		assert.Equal(t, scan.HostList{"10.0.0.2-10.0.0.5"}, *hl)
+	})
+}
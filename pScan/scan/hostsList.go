@@ -2,6 +2,7 @@ package scan
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -13,26 +14,63 @@ var (
 	ErrNotExists = errors.New("host not in the list")
 )
 
+// HostList holds host names, addresses, CIDR blocks and address ranges.
+// Blocks and ranges are kept as one entry and only expanded by Hosts.
 type HostList []string
 
+// entry parses a host of the list. Entries saved before targets were
+// validated are kept as they are.
+func entry(host string) target {
+	t, err := parseTarget(host)
+	if err != nil {
+		return target{name: host}
+	}
+	return t
+}
+
+// Add adds host in its normalised form. It fails if host, or every address
+// of it, is already in the list.
 func (hl *HostList) Add(host string) error {
-	if idx := slices.Index(*hl, host); idx > -1 {
-		return fmt.Errorf("%s: %w", host, ErrExists)
+	t, err := parseTarget(host)
+	if err != nil {
+		return err
+	}
+	for _, h := range *hl {
+		if entry(h).contains(t) {
+			return fmt.Errorf("%s: %w", host, ErrExists)
+		}
 	}
-	*hl = append(*hl, host)
+	*hl = append(*hl, t.String())
 	return nil
 }
 
 func (hl *HostList) Remove(host string) error {
-	if idx := slices.Index(*hl, host); idx > -1 {
+	name := host
+	if t, err := parseTarget(host); err == nil {
+		name = t.String()
+	}
+	if idx := slices.Index(*hl, name); idx > -1 {
 		*hl = slices.Delete(*hl, idx, idx+1)
 		return nil
 	}
 	return fmt.Errorf("%s: %w", host, ErrNotExists)
 }
 
+// Hosts expands the list into the hosts to scan, in order and without
+// duplicates.
+func (hl HostList) Hosts() []string {
+	targets := make([]Target, 0, len(hl))
+	for _, h := range hl {
+		targets = append(targets, Target{Host: h})
+	}
+	hosts, _ := expand(targets)
+	return hosts
+}
+
+// Load reads the hosts of hostFile, in the line based format or the
+// structured one of Inventory.
 func (hl *HostList) Load(hostFile string) error {
-	f, err := os.Open(hostFile)
+	data, err := os.ReadFile(hostFile)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil
@@ -40,15 +78,24 @@ func (hl *HostList) Load(hostFile string) error {
 		return err
 	}
 
-	defer f.Close()
+	if structured(data) {
+		inv, err := LoadInventory(hostFile)
+		if err != nil {
+			return err
+		}
+		*hl = append(*hl, inv.HostList()...)
+		return nil
+	}
 
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		*hl = append(*hl, scanner.Text())
 	}
 	return nil
 }
 
+// Save writes the line based format, use Inventory to keep groups, tags and
+// ports.
 func (hl *HostList) Save(hostFile string) error {
 	output := ""
 	for _, host := range *hl {
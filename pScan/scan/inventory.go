@@ -0,0 +1,221 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidInventory = errors.New("invalid hosts file")
+
+// Host is an entry of the hosts file. Ports, when set, replace those of
+// its groups.
+type Host struct {
+	Host   string   `yaml:"host" json:"host"`
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Tags   []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Ports  []int    `yaml:"ports,omitempty" json:"ports,omitempty"`
+}
+
+// Group holds the settings shared by the hosts of a group.
+type Group struct {
+	Ports []int `yaml:"ports,omitempty" json:"ports,omitempty"`
+}
+
+// Inventory is the structured hosts file, YAML or JSON when the file name
+// ends with .json:
+//
+//	groups:
+//	  web:
+//	    ports: [80, 443]
+//	hosts:
+//	  - host: 10.0.0.0/28
+//	    groups: [web]
+//	    tags: [prod]
+//	  - host: db.example.com
+//	    ports: [5432]
+type Inventory struct {
+	Groups map[string]Group `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Hosts  []Host           `yaml:"hosts" json:"hosts"`
+
+	// Migrated is set when LoadInventory converted a line based hosts file.
+	Migrated bool `yaml:"-" json:"-"`
+}
+
+// LoadInventory reads file, which may not exist yet. A hosts file in the
+// line based format of HostList is migrated: it is rewritten in the
+// structured format, the original is kept with a .bak suffix.
+func LoadInventory(file string) (*Inventory, error) {
+	inv := &Inventory{}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return inv, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return inv, nil
+	}
+
+	if !structured(data) {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				inv.Hosts = append(inv.Hosts, Host{Host: line})
+			}
+		}
+		if err := os.WriteFile(file+".bak", data, 0644); err != nil {
+			return nil, err
+		}
+		if err := inv.Save(file); err != nil {
+			return nil, err
+		}
+		inv.Migrated = true
+		return inv, nil
+	}
+
+	if err := yaml.Unmarshal(data, inv); err != nil {
+		return nil, fmt.Errorf("%w %s: %s", ErrInvalidInventory, file, err)
+	}
+	if err := inv.validate(); err != nil {
+		return nil, fmt.Errorf("%w %s: %s", ErrInvalidInventory, file, err)
+	}
+	return inv, nil
+}
+
+var inventoryKey = regexp.MustCompile(`(?m)^(hosts|groups)\s*:`)
+
+// structured reports whether data is meant to be an Inventory rather than a
+// list of hosts, even if it is not a valid one, so a broken file is never
+// migrated. Host names cannot have a colon and addresses do not end with one.
+func structured(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) || inventoryKey.Match(data)
+}
+
+func validatePorts(ports []int) error {
+	for _, p := range ports {
+		if p < 1 || p > 65535 {
+			return fmt.Errorf("invalid port %d, should be from 1 to 65535", p)
+		}
+	}
+	return nil
+}
+
+func (inv *Inventory) validate() error {
+	for name, g := range inv.Groups {
+		if err := validatePorts(g.Ports); err != nil {
+			return fmt.Errorf("group %s: %w", name, err)
+		}
+	}
+	for _, h := range inv.Hosts {
+		if h.Host == "" {
+			return errors.New("host without a name")
+		}
+		if err := validatePorts(h.Ports); err != nil {
+			return fmt.Errorf("host %s: %w", h.Host, err)
+		}
+	}
+	return nil
+}
+
+func (inv *Inventory) Save(file string) error {
+	var data []byte
+	var err error
+	if filepath.Ext(file) == ".json" {
+		data, err = json.MarshalIndent(inv, "", "  ")
+		data = append(data, '\n')
+	} else {
+		b := &bytes.Buffer{}
+		enc := yaml.NewEncoder(b)
+		enc.SetIndent(2)
+		err = enc.Encode(inv)
+		data = b.Bytes()
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// HostList returns the names of the hosts.
+func (inv *Inventory) HostList() HostList {
+	hl := make(HostList, 0, len(inv.Hosts))
+	for _, h := range inv.Hosts {
+		hl = append(hl, h.Host)
+	}
+	return hl
+}
+
+// Add adds h, with the normalisation and duplicate checks of HostList.Add.
+func (inv *Inventory) Add(h Host) error {
+	if err := validatePorts(h.Ports); err != nil {
+		return err
+	}
+	hl := inv.HostList()
+	if err := hl.Add(h.Host); err != nil {
+		return err
+	}
+	h.Host = hl[len(hl)-1]
+	inv.Hosts = append(inv.Hosts, h)
+	return nil
+}
+
+func (inv *Inventory) Remove(host string) error {
+	hl := inv.HostList()
+	if err := hl.Remove(host); err != nil {
+		return err
+	}
+	for i, h := range inv.Hosts {
+		if !slices.Contains(hl, h.Host) {
+			inv.Hosts = slices.Delete(inv.Hosts, i, i+1)
+			break
+		}
+	}
+	return nil
+}
+
+// Select returns the hosts in any of groups and with all of tags. Empty
+// groups or tags do not filter.
+func (inv *Inventory) Select(groups, tags []string) []Host {
+	hosts := []Host{}
+	for _, h := range inv.Hosts {
+		if len(groups) > 0 && !slices.ContainsFunc(groups, func(g string) bool { return slices.Contains(h.Groups, g) }) {
+			continue
+		}
+		if slices.ContainsFunc(tags, func(t string) bool { return !slices.Contains(h.Tags, t) }) {
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// Ports returns the ports to scan on h: its own, or those of its groups, or
+// defaults when neither sets any.
+func (inv *Inventory) Ports(h Host, defaults []int) []int {
+	if len(h.Ports) > 0 {
+		return h.Ports
+	}
+	ports := []int{}
+	seen := map[int]bool{}
+	for _, name := range h.Groups {
+		for _, p := range inv.Groups[name].Ports {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return defaults
+	}
+	return ports
+}
@@ -0,0 +1,110 @@
+package scan_test
+
+import (
+	"go-cmd-book/pScan/scan"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventory(t *testing.T) {
+	t.Run("migrate", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "pScan.hosts")
+		assert.NoError(t, os.WriteFile(file, []byte("host1\n10.0.0.0/30\n\n"), 0644))
+
+		inv, err := scan.LoadInventory(file)
+		assert.NoError(t, err)
+		assert.True(t, inv.Migrated)
+		assert.Equal(t, []scan.Host{{Host: "host1"}, {Host: "10.0.0.0/30"}}, inv.Hosts)
+
+		backup, err := os.ReadFile(file + ".bak")
+		assert.NoError(t, err)
+		assert.Equal(t, "host1\n10.0.0.0/30\n\n", string(backup))
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "hosts:\n  - host: host1\n  - host: 10.0.0.0/30\n", string(data))
+
+		// only the first load migrates
+		inv, err = scan.LoadInventory(file)
+		assert.NoError(t, err)
+		assert.False(t, inv.Migrated)
+		hl := &scan.HostList{}
+		assert.NoError(t, hl.Load(file))
+		assert.Equal(t, scan.HostList{"host1", "10.0.0.0/30"}, *hl)
+	})
+
+	t.Run("save", func(t *testing.T) {
+		for _, name := range []string{"hosts.yaml", "hosts.json"} {
+			file := filepath.Join(t.TempDir(), name)
+			inv := &scan.Inventory{Groups: map[string]scan.Group{"web": {Ports: []int{80, 443}}}}
+			assert.NoError(t, inv.Add(scan.Host{Host: "Web1.example.com", Groups: []string{"web"}, Tags: []string{"prod"}}))
+			assert.NoError(t, inv.Add(scan.Host{Host: "db", Ports: []int{5432}}))
+			assert.NoError(t, inv.Save(file))
+
+			loaded, err := scan.LoadInventory(file)
+			assert.NoError(t, err)
+			assert.Equal(t, inv, loaded, name)
+			assert.Equal(t, "web1.example.com", loaded.Hosts[0].Host)
+		}
+	})
+
+	t.Run("json is detected by its name", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "hosts.json")
+		assert.NoError(t, (&scan.Inventory{Hosts: []scan.Host{{Host: "host1"}}}).Save(file))
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"hosts": [{"host": "host1"}]}`, string(data))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, content := range []string{"hosts:\n  - host: a\n    ports: [0]\n", "hosts:\n  - ports: [22]\n", "hosts: [1, 2\n", "groups:\n  web:\n    ports: [70000]\n"} {
+			file := filepath.Join(t.TempDir(), "pScan.hosts")
+			assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+			_, err := scan.LoadInventory(file)
+			assert.ErrorIs(t, err, scan.ErrInvalidInventory, content)
+		}
+
+		inv := &scan.Inventory{}
+		assert.Error(t, inv.Add(scan.Host{Host: "host1", Ports: []int{65536}}))
+		assert.ErrorIs(t, inv.Add(scan.Host{Host: "bad host"}), scan.ErrInvalidHost)
+	})
+
+	t.Run("select and ports", func(t *testing.T) {
+		inv := &scan.Inventory{
+			Groups: map[string]scan.Group{"web": {Ports: []int{80, 443}}, "admin": {Ports: []int{22, 443}}},
+			Hosts: []scan.Host{
+				{Host: "web1", Groups: []string{"web"}, Tags: []string{"prod"}},
+				{Host: "web2", Groups: []string{"web", "admin"}, Tags: []string{"prod", "eu"}},
+				{Host: "web3", Groups: []string{"web"}, Tags: []string{"staging"}, Ports: []int{8080}},
+				{Host: "db", Tags: []string{"prod"}},
+			},
+		}
+		names := func(hosts []scan.Host) []string {
+			n := []string{}
+			for _, h := range hosts {
+				n = append(n, h.Host)
+			}
+			return n
+		}
+		assert.Equal(t, []string{"web1", "web2", "web3", "db"}, names(inv.Select(nil, nil)))
+		assert.Equal(t, []string{"web1", "web2", "web3"}, names(inv.Select([]string{"web"}, nil)))
+		assert.Equal(t, []string{"web1", "web2", "db"}, names(inv.Select(nil, []string{"prod"})))
+		assert.Equal(t, []string{"web2"}, names(inv.Select([]string{"web"}, []string{"prod", "eu"})))
+		assert.Empty(t, inv.Select([]string{"mail"}, nil))
+
+		defaults := []int{22}
+		assert.Equal(t, []int{80, 443}, inv.Ports(inv.Hosts[0], defaults))
+		assert.Equal(t, []int{80, 443, 22}, inv.Ports(inv.Hosts[1], defaults))
+		assert.Equal(t, []int{8080}, inv.Ports(inv.Hosts[2], defaults))
+		assert.Equal(t, defaults, inv.Ports(inv.Hosts[3], defaults))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		inv := &scan.Inventory{Hosts: []scan.Host{{Host: "host1"}, {Host: "host2", Tags: []string{"a"}}, {Host: "host3"}}}
+		assert.NoError(t, inv.Remove("HOST2"))
+		assert.Equal(t, []scan.Host{{Host: "host1"}, {Host: "host3"}}, inv.Hosts)
+		assert.ErrorIs(t, inv.Remove("host2"), scan.ErrNotExists)
+	})
+}
@@ -0,0 +1,90 @@
+package scan
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"sort"
+)
+
+// job is a port of a host to probe, as indexes in the results.
+type job struct{ host, port int }
+
+// jobs maps the probes of a run to their indexes without storing them. They
+// go round the hosts, the first port of each host, then the second one, so
+// the workers spread over the hosts instead of waiting on the slots of one.
+type jobs struct {
+	// hosts are the host indexes, the ones with the most ports first, so
+	// the hosts of a round are a prefix of it.
+	hosts []int
+	// rounds are the indexes of the first job of each round.
+	rounds []int
+	n      int
+	random *permutation
+}
+
+// newJobs returns the jobs for hosts with counts ports each, in a random
+// order when random is set.
+func newJobs(counts []int, random bool) jobs {
+	j := jobs{hosts: make([]int, len(counts))}
+	for h, c := range counts {
+		j.hosts[h] = h
+		j.n += c
+	}
+	sort.SliceStable(j.hosts, func(a, b int) bool { return counts[j.hosts[a]] > counts[j.hosts[b]] })
+
+	active := len(j.hosts)
+	for r, start := 0, 0; start < j.n; r++ {
+		for active > 0 && counts[j.hosts[active-1]] <= r {
+			active--
+		}
+		j.rounds = append(j.rounds, start)
+		start += active
+	}
+	if random && j.n > 0 {
+		p := newPermutation(j.n)
+		j.random = &p
+	}
+	return j
+}
+
+func (j jobs) at(i int) job {
+	if j.random != nil {
+		i = j.random.at(i)
+	}
+	r := sort.Search(len(j.rounds), func(r int) bool { return j.rounds[r] > i }) - 1
+	return job{j.hosts[i-j.rounds[r]], r}
+}
+
+// permutation is a random bijection of [0, n) computed for each index
+// instead of stored: keyed rounds mix the bits of the index up to the next
+// power of two, and are applied again while the result is n or more.
+type permutation struct {
+	n     uint64
+	shift int
+	mask  uint64
+	keys  [4]uint64
+}
+
+func newPermutation(n int) permutation {
+	width := max(bits.Len(uint(n-1)), 1)
+	p := permutation{n: uint64(n), shift: width/2 + 1, mask: 1<<width - 1}
+	for i := range p.keys {
+		p.keys[i] = rand.Uint64()
+	}
+	return p
+}
+
+func (p permutation) at(i int) int {
+	x := uint64(i)
+	for {
+		// each step is a bijection of the values up to mask
+		for _, k := range p.keys {
+			x ^= x >> p.shift
+			x = x * (k | 1) & p.mask
+			x = (x + k>>32) & p.mask
+		}
+		if x < p.n {
+			return int(x)
+		}
+	}
+}
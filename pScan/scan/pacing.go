@@ -0,0 +1,112 @@
+package scan
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits for it, so pacing can be tested without
+// sleeping.
+type Clock interface {
+	Now() time.Time
+	// SleepUntil waits until t, or returns the error of ctx when it is done
+	// first.
+	SleepUntil(ctx context.Context, t time.Time) error
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) SleepUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pacer delays the probes to send at most rate of them per second, each
+// after a random jitter.
+type pacer struct {
+	clock  Clock
+	every  time.Duration
+	jitter time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newPacer(clock Clock, rate float64, jitter time.Duration) *pacer {
+	p := &pacer{clock: clock, jitter: jitter}
+	if rate > 0 {
+		p.every = time.Duration(float64(time.Second) / rate)
+	}
+	return p
+}
+
+// wait blocks until the next probe can be sent. Every call takes its own
+// slot, so concurrent workers are paced as a whole.
+func (p *pacer) wait(ctx context.Context) error {
+	if p.every <= 0 && p.jitter <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	t := p.clock.Now()
+	if p.every > 0 {
+		t = later(t, p.next)
+		p.next = t.Add(p.every)
+	}
+	p.mu.Unlock()
+
+	if p.jitter > 0 {
+		t = t.Add(rand.N(p.jitter))
+	}
+	return p.clock.SleepUntil(ctx, t)
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// hostLimit bounds the probes running at once on each host.
+type hostLimit []chan struct{}
+
+func newHostLimit(hosts, workers int) hostLimit {
+	if workers <= 0 {
+		return nil
+	}
+	l := make(hostLimit, hosts)
+	for i := range l {
+		l[i] = make(chan struct{}, workers)
+	}
+	return l
+}
+
+func (l hostLimit) acquire(ctx context.Context, host int) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l[host] <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l hostLimit) release(host int) {
+	if l != nil {
+		<-l[host]
+	}
+}
@@ -0,0 +1,225 @@
+package scan_test
+
+import (
+	"context"
+	"errors"
+	"go-cmd-book/pScan/scan"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock jumps to the time slept until instead of waiting.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+	// wakes are the times slept until.
+	wakes []time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) SleepUntil(ctx context.Context, t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wakes = append(c.wakes, t)
+	if t.After(c.now) {
+		c.now = t
+	}
+	return ctx.Err()
+}
+
+// probe is a connection attempt seen by recordDial.
+type probe struct {
+	host string
+	port int
+	at   time.Time
+}
+
+// recordDial records the probes, on clock when set, and fails them all.
+func recordDial(clock scan.Clock, delay time.Duration) (scan.DialFunc, func() []probe) {
+	mu := sync.Mutex{}
+	probes := []probe{}
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, _ := net.SplitHostPort(address)
+		p := probe{host: host}
+		p.port, _ = strconv.Atoi(port)
+		if clock != nil {
+			p.at = clock.Now()
+		}
+		mu.Lock()
+		probes = append(probes, p)
+		mu.Unlock()
+		time.Sleep(delay)
+		return nil, errors.New("connection refused")
+	}
+	return dial, func() []probe {
+		mu.Lock()
+		defer mu.Unlock()
+		return probes
+	}
+}
+
+func portRange(first, n int) []int {
+	ports := make([]int, n)
+	for i := range ports {
+		ports[i] = first + i
+	}
+	return ports
+}
+
+func TestPacing(t *testing.T) {
+	start := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	hl := &scan.HostList{"127.0.0.1"}
+	ports := portRange(1000, 20)
+
+	t.Run("rate", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		dial, probes := recordDial(clock, 0)
+		_, err := scan.RunContext(context.Background(), hl, ports, "tcp", scan.Options{Workers: 1, Dial: dial, Rate: 10, Clock: clock})
+		assert.NoError(t, err)
+		assert.Len(t, probes(), len(ports))
+		for i, p := range probes() {
+			assert.Equal(t, start.Add(time.Duration(i)*100*time.Millisecond), p.at)
+		}
+	})
+
+	t.Run("rate shared by workers", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		dial, _ := recordDial(clock, 0)
+		_, err := scan.RunContext(context.Background(), hl, ports, "tcp", scan.Options{Workers: 8, Dial: dial, Rate: 4, Clock: clock})
+		assert.NoError(t, err)
+		assert.Len(t, clock.wakes, len(ports))
+		seen := map[time.Time]bool{}
+		for _, w := range clock.wakes {
+			assert.False(t, seen[w], "two probes at %s", w)
+			seen[w] = true
+			assert.Zero(t, w.Sub(start)%(250*time.Millisecond))
+		}
+		assert.Equal(t, start.Add(19*250*time.Millisecond), clock.Now())
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		dial, probes := recordDial(clock, 0)
+		jitter := 50 * time.Millisecond
+		_, err := scan.RunContext(context.Background(), hl, ports, "tcp", scan.Options{Workers: 1, Dial: dial, Jitter: jitter, Clock: clock})
+		assert.NoError(t, err)
+		last := start
+		for _, p := range probes() {
+			assert.GreaterOrEqual(t, p.at.Sub(last), time.Duration(0))
+			assert.Less(t, p.at.Sub(last), jitter)
+			last = p.at
+		}
+		assert.True(t, last.After(start))
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		dial, probes := recordDial(clock, 0)
+		_, err := scan.RunContext(context.Background(), hl, ports[:3], "tcp", scan.Options{Workers: 2, Dial: dial, Rate: 10, Retries: 2, Clock: clock})
+		assert.NoError(t, err)
+		assert.Len(t, probes(), 9)
+		assert.Len(t, clock.wakes, 9)
+		assert.Equal(t, start.Add(8*100*time.Millisecond), clock.Now())
+	})
+
+	t.Run("no pacing", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		dial, _ := recordDial(clock, 0)
+		_, err := scan.RunContext(context.Background(), hl, ports, "tcp", scan.Options{Dial: dial, Clock: clock})
+		assert.NoError(t, err)
+		assert.Empty(t, clock.wakes)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		dial, _ := recordDial(nil, 0)
+		begin := time.Now()
+		_, err := scan.RunContext(ctx, hl, ports, "tcp", scan.Options{Workers: 1, Dial: dial, Rate: 1})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(begin), 500*time.Millisecond)
+	})
+}
+
+func TestHostWorkers(t *testing.T) {
+	mu := sync.Mutex{}
+	running := map[string]int{}
+	most := map[string]int{}
+	total, mostTotal := 0, 0
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(address)
+		mu.Lock()
+		running[host]++
+		total++
+		most[host] = max(most[host], running[host])
+		mostTotal = max(mostTotal, total)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running[host]--
+		total--
+		mu.Unlock()
+		return nil, errors.New("connection refused")
+	}
+
+	hl := &scan.HostList{"127.0.0.1", "127.0.0.2"}
+	_, err := scan.RunContext(context.Background(), hl, portRange(1000, 10), "tcp", scan.Options{Workers: 16, HostWorkers: 2, Dial: dial})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"127.0.0.1": 2, "127.0.0.2": 2}, most)
+	assert.Equal(t, 4, mostTotal)
+
+	// fewer workers than ports on a host, they still reach every host
+	most, mostTotal = map[string]int{}, 0
+	hl = &scan.HostList{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"}
+	_, err = scan.RunContext(context.Background(), hl, portRange(1000, 10), "tcp", scan.Options{Workers: 8, HostWorkers: 1, Dial: dial})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"127.0.0.1": 1, "127.0.0.2": 1, "127.0.0.3": 1, "127.0.0.4": 1}, most)
+	assert.Equal(t, 4, mostTotal)
+}
+
+func TestRandomOrder(t *testing.T) {
+	ports := portRange(1000, 50)
+	hl := &scan.HostList{"127.0.0.1", "127.0.0.2"}
+	dial, probes := recordDial(nil, 0)
+	results, err := scan.RunContext(context.Background(), hl, ports, "tcp", scan.Options{Workers: 1, Dial: dial, RandomOrder: true})
+	assert.NoError(t, err)
+
+	ordered := []probe{}
+	for _, h := range *hl {
+		for _, p := range ports {
+			ordered = append(ordered, probe{host: h, port: p})
+		}
+	}
+	assert.ElementsMatch(t, ordered, probes())
+	assert.NotEqual(t, ordered, probes())
+
+	for i, r := range results {
+		assert.Equal(t, (*hl)[i], r.Host)
+		for j, p := range r.PortStates {
+			assert.Equal(t, ports[j], p.Port)
+		}
+	}
+
+	// every port is probed once whatever the number of them
+	for _, n := range []int{1, 2, 3, 17, 64, 65} {
+		dial, probes := recordDial(nil, 0)
+		hl := &scan.HostList{"127.0.0.1"}
+		_, err := scan.RunContext(context.Background(), hl, portRange(1000, n), "tcp", scan.Options{Workers: 1, Dial: dial, RandomOrder: true})
+		assert.NoError(t, err)
+		seen := []int{}
+		for _, p := range probes() {
+			seen = append(seen, p.port)
+		}
+		assert.ElementsMatch(t, portRange(1000, n), seen)
+	}
+}
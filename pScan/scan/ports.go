@@ -0,0 +1,117 @@
+package scan
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PortSets are the named sets of ports a port spec can use.
+var PortSets = map[string][]int{
+	"web":    {80, 443, 8000, 8008, 8080, 8443, 8888},
+	"db":     {1433, 1521, 3306, 5432, 6379, 9042, 9200, 11211, 27017},
+	"mail":   {25, 110, 143, 465, 587, 993, 995},
+	"remote": {22, 23, 3389, 5900, 5985, 5986},
+	"file":   {21, 139, 445, 873, 2049},
+	"dns":    {53},
+	"ldap":   {389, 636, 3268, 3269},
+}
+
+// topPorts are the most frequently open ports, most frequent first, as
+// ranked by the nmap-services list.
+var topPorts = map[string][]int{
+	"tcp": {
+		80, 23, 443, 21, 22, 25, 3389, 110, 445, 139,
+		143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+		1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001,
+		10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768, 554,
+		26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646,
+		5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800, 106,
+		2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543,
+		544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+		7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051,
+		6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37,
+	},
+	"udp": {
+		631, 161, 137, 123, 138, 1434, 445, 135, 67, 53,
+		139, 500, 68, 520, 1900, 4500, 514, 49152, 162, 69,
+		5353, 111, 49154, 1701, 998, 996, 997, 999, 3283, 49153,
+		1812, 136, 2222, 2049, 32768, 5060, 1025, 1433, 3456, 80,
+		20031, 1026, 7, 1646, 1645, 593, 518, 2048, 626, 1027,
+	},
+}
+
+// TopPorts returns the n most frequently open ports of proto.
+func TopPorts(proto string, n int) ([]int, error) {
+	ports, ok := topPorts[proto]
+	if !ok {
+		return nil, fmt.Errorf("invalid protocol, should be tcp/udp %s", proto)
+	}
+	if n < 1 || n > len(ports) {
+		return nil, fmt.Errorf("invalid top %d, should be from 1 to %d for %s", n, len(ports), proto)
+	}
+	return slices.Clone(ports[:n]), nil
+}
+
+// ParsePorts parses port specs, each a port, a range like 8000-8100 or the
+// name of a port set, and returns their ports in order without duplicates.
+func ParsePorts(specs ...string) ([]int, error) {
+	ports := []int{}
+	seen := map[int]bool{}
+	add := func(p int) {
+		if !seen[p] {
+			seen[p] = true
+			ports = append(ports, p)
+		}
+	}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if set, ok := PortSets[strings.ToLower(spec)]; ok {
+			for _, p := range set {
+				add(p)
+			}
+			continue
+		}
+
+		first, last, isRange := strings.Cut(spec, "-")
+		lo, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+		hi := lo
+		if isRange {
+			if hi, err = parsePort(last); err != nil {
+				return nil, err
+			}
+			if hi < lo {
+				return nil, fmt.Errorf("invalid port range %s, the end is before the start", spec)
+			}
+		}
+		for p := lo; p <= hi; p++ {
+			add(p)
+		}
+	}
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q, should be a number, a range or one of %s", s, strings.Join(PortSetNames(), ", "))
+	}
+	if err := validatePorts([]int{p}); err != nil {
+		return 0, err
+	}
+	return p, nil
+}
+
+// PortSetNames returns the names of PortSets, sorted.
+func PortSetNames() []string {
+	names := make([]string, 0, len(PortSets))
+	for name := range PortSets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,68 @@
+package scan_test
+
+import (
+	"go-cmd-book/pScan/scan"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePorts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		specs    []string
+		expected []int
+		expErr   string
+	}{
+		{name: "ports", specs: []string{"22", "80"}, expected: []int{22, 80}},
+		{name: "range", specs: []string{"8000-8003"}, expected: []int{8000, 8001, 8002, 8003}},
+		{name: "set", specs: []string{"Mail"}, expected: []int{25, 110, 143, 465, 587, 993, 995}},
+		{name: "mixed", specs: []string{"web", "8000-8001", "9200", "80"}, expected: []int{80, 443, 8000, 8008, 8080, 8443, 8888, 8001, 9200}},
+		{name: "bounds", specs: []string{"1", "65535"}, expected: []int{1, 65535}},
+		{name: "none", expected: []int{}},
+		{name: "zero", specs: []string{"0"}, expErr: "invalid port 0, should be from 1 to 65535"},
+		{name: "too high", specs: []string{"60000-70000"}, expErr: "invalid port 70000, should be from 1 to 65535"},
+		{name: "reversed", specs: []string{"1024-1"}, expErr: "invalid port range 1024-1, the end is before the start"},
+		{name: "negative", specs: []string{"-5"}, expErr: `invalid port ""`},
+		{name: "unknown set", specs: []string{"games"}, expErr: `invalid port "games", should be a number, a range or one of db, dns, file, ldap, mail, remote, web`},
+		{name: "empty", specs: []string{""}, expErr: `invalid port ""`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports, err := scan.ParsePorts(tc.specs...)
+			if tc.expErr != "" {
+				assert.ErrorContains(t, err, tc.expErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, ports)
+		})
+	}
+}
+
+func TestTopPorts(t *testing.T) {
+	ports, err := scan.TopPorts("tcp", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{80, 23, 443, 21, 22}, ports)
+
+	ports, err = scan.TopPorts("udp", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{631, 161, 137}, ports)
+
+	for _, proto := range []string{"tcp", "udp"} {
+		ports, err := scan.TopPorts(proto, 50)
+		assert.NoError(t, err)
+		seen := map[int]bool{}
+		for _, p := range ports {
+			assert.False(t, seen[p], "%s port %d ranked twice", proto, p)
+			seen[p] = true
+		}
+	}
+
+	_, err = scan.TopPorts("tcp", 0)
+	assert.Error(t, err)
+	_, err = scan.TopPorts("tcp", 101)
+	assert.ErrorContains(t, err, "should be from 1 to 100")
+	_, err = scan.TopPorts("sctp", 10)
+	assert.Error(t, err)
+}
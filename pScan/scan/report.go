@@ -0,0 +1,49 @@
+package scan
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Report is a complete scan, the document written by the structured
+// output formats.
+type Report struct {
+	Protocol string    `json:"protocol"`
+	Ports    []int     `json:"ports"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Results  []Results `json:"results"`
+}
+
+// portJSON is the serialised form of PortState. Its fields must not be
+// renamed, other tools read them.
+type portJSON struct {
+	Port    int    `json:"port"`
+	State   string `json:"state"`
+	Service string `json:"service,omitempty"`
+	Version string `json:"version,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+}
+
+func (p PortState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(portJSON{
+		Port:    p.Port,
+		State:   p.State.String(),
+		Service: p.Service,
+		Version: p.Version,
+		Banner:  p.Banner,
+	})
+}
+
+func (p *PortState) UnmarshalJSON(data []byte) error {
+	v := portJSON{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	state, err := ParseState(v.State)
+	if err != nil {
+		return err
+	}
+	*p = PortState{Port: v.Port, State: state, Service: v.Service, Version: v.Version, Banner: v.Banner}
+	return nil
+}
@@ -1,64 +1,340 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"slices"
+	"sync"
+	"syscall"
 	"time"
 )
 
-type state bool
+var ErrTooManyProbes = errors.New("too many probes")
 
-func (s state) String() string {
-	if s {
-		return "open"
+// MaxProbes is the largest number of ports a run can scan over all hosts.
+const MaxProbes = 1 << 22
+
+// State is the state of a scanned port.
+type State int
+
+const (
+	// Closed ports refused the connection, or answered a UDP probe with
+	// an ICMP port unreachable.
+	Closed State = iota
+	Open
+	// Filtered ports did not answer a TCP connection before the timeout.
+	Filtered
+	// OpenFiltered ports did not answer a UDP probe, so they are either
+	// open or filtered.
+	OpenFiltered
+	// Unreachable ports could not be probed because of a network error,
+	// like no route to the host.
+	Unreachable
+)
+
+var stateNames = []string{
+	Closed:       "closed",
+	Open:         "open",
+	Filtered:     "filtered",
+	OpenFiltered: "open|filtered",
+	Unreachable:  "unreachable",
+}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+// ParseState returns the State named s.
+func ParseState(s string) (State, error) {
+	for i, name := range stateNames {
+		if name == s {
+			return State(i), nil
+		}
 	}
-	return "closed"
+	return Closed, fmt.Errorf("invalid port state %q", s)
 }
 
 type PortState struct {
-	Port int
-	Open state
+	Port  int
+	State State
+	// Service, Version and Banner are set by banner grabbing, when the
+	// port answered. Service and Version are empty if no signature matched.
+	Service string
+	Version string
+	Banner  string
 }
 
-func scanPort(host string, port int, proto string) PortState {
-	p := PortState{
-		Port: port,
-	}
+// DialFunc opens a connection, like net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// scanPort probes port, again up to opts.Retries times while it is filtered
+// or unreachable. The retries are paced like the first probe, which is
+// already when scanPort is called.
+func scanPort(ctx context.Context, host string, port int, proto string, opts Options, pace *pacer) PortState {
+	p := PortState{Port: port}
 	addr := net.JoinHostPort(host, fmt.Sprintf("%d", p.Port))
-	scanConn, err := net.DialTimeout(proto, addr, 1*time.Second)
+	for try := 0; try <= opts.Retries && ctx.Err() == nil; try++ {
+		if try > 0 && pace.wait(ctx) != nil {
+			break
+		}
+		if proto == "udp" {
+			p.State = probeUDP(ctx, opts.Dial, addr, port, opts.UDPTimeout)
+		} else {
+			p = probeTCP(ctx, addr, port, opts)
+		}
+		// a silent UDP port stays silent, only a timeout or an
+		// unreachable host is worth another try
+		if p.State != Filtered && p.State != Unreachable {
+			break
+		}
+	}
+	return p
+}
+
+func probeTCP(ctx context.Context, addr string, port int, opts Options) PortState {
+	p := PortState{Port: port}
+	dialCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	scanConn, err := opts.Dial(dialCtx, "tcp", addr)
 	if err != nil {
-		p.Open = false
+		p.State = dialState(err)
 		return p
 	}
-	scanConn.Close()
-	p.Open = true
+	defer scanConn.Close()
+	p.State = Open
+	if opts.Banners {
+		p.Service, p.Version, p.Banner = grabBanner(scanConn, port, opts.BannerTimeout)
+	}
 	return p
 }
 
+// dialState returns the state of a port a connection could not be opened
+// to.
+func dialState(err error) State {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return Closed
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return Filtered
+	}
+	return Unreachable
+}
+
 type Results struct {
-	Host       string
-	NotFound   bool
-	PortStates []PortState
+	Host string `json:"host"`
+	// Address is the first address a host name resolved to, empty when
+	// Host is an address.
+	Address    string      `json:"address,omitempty"`
+	NotFound   bool        `json:"not_found"`
+	PortStates []PortState `json:"ports,omitempty"`
+}
+
+const (
+	// DefaultWorkers is the number of probes run at once when Options sets
+	// none.
+	DefaultWorkers = 100
+	// DefaultTimeout is how long a TCP connection can take when Options
+	// sets no Timeout.
+	DefaultTimeout = 1 * time.Second
+)
+
+type Options struct {
+	// Workers bounds the number of lookups and probes running at once.
+	Workers int
+	// Dial opens the probe connections, a net.Dialer by default.
+	Dial DialFunc
+	// Timeout is how long a TCP connection can take, DefaultTimeout by
+	// default.
+	Timeout time.Duration
+	// Retries is how many more times a port is probed when it is filtered
+	// or unreachable.
+	Retries int
+	// UDPTimeout is how long a UDP probe waits for a reply,
+	// DefaultUDPTimeout by default.
+	UDPTimeout time.Duration
+	// Banners enables banner grabbing on open TCP ports.
+	Banners bool
+	// BannerTimeout is how long banner grabbing waits for each read,
+	// DefaultBannerTimeout by default.
+	BannerTimeout time.Duration
+
+	// Rate is the most probes sent per second over all hosts, unlimited
+	// when 0.
+	Rate float64
+	// HostWorkers bounds the probes running at once on each host,
+	// unlimited when 0.
+	HostWorkers int
+	// Jitter is the longest random delay before each probe.
+	Jitter time.Duration
+	// RandomOrder probes the ports and hosts in a random order instead of
+	// going round the hosts port by port. The results keep their order.
+	RandomOrder bool
+	// Clock paces the probes, the system clock by default.
+	Clock Clock
 }
 
 func Run(hl *HostList, ports []int, proto string) []Results {
-	results := make([]Results, 0, len(*hl))
-	// results := []Results{}
-	for _, host := range *hl {
-		r := Results{
-			Host: host,
+	results, _ := RunContext(context.Background(), hl, ports, proto, Options{})
+	return results
+}
+
+// RunContext scans ports on every host of hl with a pool of workers. The
+// results keep the order of hl and ports. It stops early and returns the
+// error of ctx when ctx is cancelled.
+func RunContext(ctx context.Context, hl *HostList, ports []int, proto string, opts Options) ([]Results, error) {
+	targets := make([]Target, 0, len(*hl))
+	for _, h := range *hl {
+		targets = append(targets, Target{Host: h, Ports: ports})
+	}
+	return RunTargets(ctx, targets, proto, opts)
+}
+
+// Target is a host list entry and the ports to scan on its hosts.
+type Target struct {
+	Host  string
+	Ports []int
+}
+
+// RunTargets is RunContext with ports set for each target. A host in several
+// targets is scanned once, on the ports of all of them. It fails with
+// ErrTooManyProbes when the targets have more than MaxProbes ports.
+func RunTargets(ctx context.Context, targets []Target, proto string, opts Options) ([]Results, error) {
+	probes := 0
+	for _, t := range targets {
+		// an upper bound, hosts and ports in several targets count each time
+		probes += entry(t.Host).size() * len(t.Ports)
+		if probes > MaxProbes {
+			return nil, fmt.Errorf("%w: more than %d ports over all hosts", ErrTooManyProbes, MaxProbes)
 		}
-		if _, err := net.LookupHost(host); err != nil {
-			r.NotFound = true
-			results = append(results, r)
-			continue
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Dial == nil {
+		opts.Dial = (&net.Dialer{}).DialContext
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.UDPTimeout <= 0 {
+		opts.UDPTimeout = DefaultUDPTimeout
+	}
+	if opts.BannerTimeout <= 0 {
+		opts.BannerTimeout = DefaultBannerTimeout
+	}
+	if opts.Clock == nil {
+		opts.Clock = realClock{}
+	}
+
+	hosts, ports := expand(targets)
+	results := make([]Results, len(hosts))
+	for i, host := range hosts {
+		results[i].Host = host
+	}
+
+	// each job fills its own slot, so the order does not depend on timing
+	pool(ctx, opts.Workers, len(results), func(i int) {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, results[i].Host)
+		if err != nil {
+			results[i].NotFound = true
+			return
 		}
+		if net.ParseIP(results[i].Host) == nil && len(addrs) > 0 {
+			results[i].Address = addrs[0]
+		}
+		results[i].PortStates = make([]PortState, len(ports[i]))
+	})
+
+	counts := make([]int, len(results))
+	for h, r := range results {
+		counts[h] = len(r.PortStates)
+	}
+	jobs := newJobs(counts, opts.RandomOrder)
 
-		for _, port := range ports {
-			r.PortStates = append(r.PortStates, scanPort(host, port, proto))
+	pace := newPacer(opts.Clock, opts.Rate, opts.Jitter)
+	limit := newHostLimit(len(results), opts.HostWorkers)
+	pool(ctx, opts.Workers, jobs.n, func(i int) {
+		j := jobs.at(i)
+		// paced first, so a worker does not hold a host slot while waiting
+		if err := pace.wait(ctx); err != nil {
+			return
+		}
+		if err := limit.acquire(ctx, j.host); err != nil {
+			return
 		}
-		results = append(results, r)
+		defer limit.release(j.host)
+		results[j.host].PortStates[j.port] = scanPort(ctx, results[j.host].Host, ports[j.host][j.port], proto, opts, pace)
+	})
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// expand returns the hosts of targets, in order and without duplicates, and
+// the ports to scan on each of them.
+func expand(targets []Target) ([]string, [][]int) {
+	hosts := []string{}
+	ports := [][]int{}
+	index := map[string]int{}
+	// seen are the ports of the hosts in several targets
+	seen := map[int]map[int]bool{}
+	for _, t := range targets {
+		for _, host := range entry(t.Host).hosts() {
+			i, ok := index[host]
+			if !ok {
+				index[host] = len(hosts)
+				hosts = append(hosts, host)
+				ports = append(ports, slices.Clone(t.Ports))
+				continue
+			}
+			if seen[i] == nil {
+				seen[i] = make(map[int]bool, len(ports[i]))
+				for _, p := range ports[i] {
+					seen[i][p] = true
+				}
+			}
+			for _, p := range t.Ports {
+				if !seen[i][p] {
+					seen[i][p] = true
+					ports[i] = append(ports[i], p)
+				}
+			}
+		}
+	}
+	return hosts, ports
+}
+
+// pool calls f for 0 to n-1 on up to workers goroutines and waits for them.
+// Once ctx is done no more calls are started.
+func pool(ctx context.Context, workers, n int, f func(i int)) {
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				f(i)
+			}
+		}()
+	}
+
+	defer wg.Wait()
+	defer close(next)
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			return
+		}
 	}
-	return results
 }
@@ -1,25 +1,40 @@
 package scan_test
 
 import (
+	"context"
 	"go-cmd-book/pScan/scan"
 	"net"
 	"strconv"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestStateString(t *testing.T) {
 	ps := scan.PortState{}
-	assert.Equal(t, "closed", ps.Open.String())
-	ps.Open = true
-	assert.Equal(t, "open", ps.Open.String())
+	assert.Equal(t, "closed", ps.State.String())
+	ps.State = scan.Open
+	assert.Equal(t, "open", ps.State.String())
+
+	for _, s := range []scan.State{scan.Closed, scan.Open, scan.Filtered, scan.OpenFiltered, scan.Unreachable} {
+		parsed, err := scan.ParseState(s.String())
+		assert.NoError(t, err)
+		assert.Equal(t, s, parsed)
+	}
+	assert.Equal(t, "State(9)", scan.State(9).String())
+	_, err := scan.ParseState("half-open")
+	assert.Error(t, err)
 }
 
 func TestRun(t *testing.T) {
 	host := "localhost"
 	hl := &scan.HostList{}
 	hl.Add(host)
+	addrs, err := net.LookupHost(host)
+	assert.NoError(t, err)
+	addr := addrs[0]
 
 	// setup listening on a random port ("0")
 	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
@@ -37,8 +52,9 @@ func TestRun(t *testing.T) {
 		assert.Equal(t, []scan.Results{
 			{
 				Host:       host,
+				Address:    addr,
 				NotFound:   false,
-				PortStates: []scan.PortState{{Open: true, Port: port}},
+				PortStates: []scan.PortState{{State: scan.Open, Port: port}},
 			},
 		}, results)
 	})
@@ -49,8 +65,9 @@ func TestRun(t *testing.T) {
 		assert.Equal(t, []scan.Results{
 			{
 				Host:       host,
+				Address:    addr,
 				NotFound:   false,
-				PortStates: []scan.PortState{{Open: false, Port: port}},
+				PortStates: []scan.PortState{{Port: port}},
 			},
 		}, results)
 
@@ -63,8 +80,9 @@ func TestRun(t *testing.T) {
 		assert.Equal(t, []scan.Results{
 			{
 				Host:       host,
+				Address:    addr,
 				NotFound:   false,
-				PortStates: []scan.PortState{{Open: false, Port: port}},
+				PortStates: []scan.PortState{{Port: port}},
 			},
 			{
 				Host:     notFound,
@@ -74,3 +92,172 @@ func TestRun(t *testing.T) {
 
 	})
 }
+
+// listen opens n local TCP listeners and returns their ports.
+func listen(t *testing.T, n int) []int {
+	t.Helper()
+	ports := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", "localhost:0")
+		assert.NoError(t, err)
+		t.Cleanup(func() { ln.Close() })
+		ports = append(ports, ln.Addr().(*net.TCPAddr).Port)
+	}
+	return ports
+}
+
+// slowDial delays every connection so the pool has waiting to overlap.
+func slowDial(delay time.Duration) scan.DialFunc {
+	d := &net.Dialer{}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	hl := &scan.HostList{}
+	assert.NoError(t, hl.Add("localhost"))
+	assert.NoError(t, hl.Add("257.257.257.257"))
+	assert.NoError(t, hl.Add("127.0.0.1"))
+
+	ports := listen(t, 20)
+	// a closed port between open ones
+	ln, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	closed := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	ports = append(ports[:10], append([]int{closed}, ports[10:]...)...)
+
+	expected := func(host string) scan.Results {
+		r := scan.Results{Host: host}
+		for _, p := range ports {
+			ps := scan.PortState{Port: p, State: scan.Open}
+			if p == closed {
+				ps.State = scan.Closed
+			}
+			r.PortStates = append(r.PortStates, ps)
+		}
+		return r
+	}
+
+	t.Run("ordered", func(t *testing.T) {
+		results, err := scan.RunContext(context.Background(), hl, ports, "tcp", scan.Options{Workers: 8})
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+		local := expected("localhost")
+		addrs, err := net.LookupHost("localhost")
+		assert.NoError(t, err)
+		local.Address = addrs[0]
+		assert.Equal(t, local, results[0])
+		assert.Equal(t, scan.Results{Host: "257.257.257.257", NotFound: true}, results[1])
+		assert.Equal(t, expected("127.0.0.1"), results[2])
+	})
+
+	t.Run("speedup", func(t *testing.T) {
+		hl := &scan.HostList{"localhost"}
+		delay := 20 * time.Millisecond
+
+		start := time.Now()
+		_, err := scan.RunContext(context.Background(), hl, ports[:10], "tcp", scan.Options{Workers: 1, Dial: slowDial(delay)})
+		assert.NoError(t, err)
+		sequential := time.Since(start)
+
+		start = time.Now()
+		_, err = scan.RunContext(context.Background(), hl, ports[:10], "tcp", scan.Options{Workers: 10, Dial: slowDial(delay)})
+		assert.NoError(t, err)
+		concurrent := time.Since(start)
+
+		assert.GreaterOrEqual(t, sequential, 10*delay)
+		assert.Less(t, concurrent, sequential/3)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		results, err := scan.RunContext(ctx, hl, ports, "tcp", scan.Options{Workers: 2, Dial: slowDial(time.Second)})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, results)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
+
+func TestRunTargets(t *testing.T) {
+	ports := listen(t, 3)
+	targets := []scan.Target{
+		{Host: "127.0.0.1", Ports: ports[:2]},
+		{Host: "127.0.0.0/31", Ports: ports[1:]},
+	}
+	results, err := scan.RunTargets(context.Background(), targets, "tcp", scan.Options{})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, scan.Results{Host: "127.0.0.1", PortStates: []scan.PortState{
+		{Port: ports[0], State: scan.Open}, {Port: ports[1], State: scan.Open}, {Port: ports[2], State: scan.Open},
+	}}, results[0])
+	assert.Equal(t, "127.0.0.0", results[1].Host)
+	assert.Len(t, results[1].PortStates, 2)
+
+	ports, err = scan.ParsePorts("1-100")
+	assert.NoError(t, err)
+	_, err = scan.RunTargets(context.Background(), []scan.Target{{Host: "10.0.0.0/16", Ports: ports}}, "tcp", scan.Options{})
+	assert.ErrorIs(t, err, scan.ErrTooManyProbes)
+}
+
+// countDial counts the connections and fails the first ones with errs.
+func countDial(dials *int, errs ...error) scan.DialFunc {
+	d := &net.Dialer{}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		*dials++
+		if *dials <= len(errs) {
+			if errs[*dials-1] == context.DeadlineExceeded {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return nil, errs[*dials-1]
+		}
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+func TestTimeoutRetries(t *testing.T) {
+	hl := &scan.HostList{"127.0.0.1"}
+	open := listen(t, 1)[0]
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closed := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.EHOSTUNREACH}
+
+	testCases := []struct {
+		name     string
+		port     int
+		errs     []error
+		retries  int
+		expected scan.State
+		dials    int
+	}{
+		{"filtered", open, []error{context.DeadlineExceeded, context.DeadlineExceeded}, 1, scan.Filtered, 2},
+		{"unreachable", open, []error{unreachable, unreachable, unreachable}, 2, scan.Unreachable, 3},
+		{"answers on retry", open, []error{context.DeadlineExceeded, unreachable}, 2, scan.Open, 3},
+		{"no retry", open, []error{unreachable}, 0, scan.Unreachable, 1},
+		{"refused is final", closed, nil, 3, scan.Closed, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dials := 0
+			start := time.Now()
+			opts := scan.Options{Workers: 1, Dial: countDial(&dials, tc.errs...), Timeout: 20 * time.Millisecond, Retries: tc.retries}
+			results, err := scan.RunContext(context.Background(), hl, []int{tc.port}, "tcp", opts)
+			assert.NoError(t, err)
+			assert.Equal(t, []scan.PortState{{Port: tc.port, State: tc.expected}}, results[0].PortStates)
+			assert.Equal(t, tc.dials, dials)
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package scan
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidHost = errors.New("invalid host")
+
+// MaxRangeSize is the largest number of addresses a CIDR block or range can
+// hold.
+const MaxRangeSize = 1 << 16
+
+var hostnameLabel = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]*[a-z0-9_])?$`)
+
+// target is an entry of the host list: a host name, an address, or a block
+// or range of addresses from first to last.
+type target struct {
+	name        string
+	prefix      netip.Prefix
+	first, last netip.Addr
+}
+
+// parseTarget parses a host name, an IPv4 or IPv6 address, a CIDR block
+// like 10.0.0.0/28, or a range like 10.0.0.1-20 or 2001:db8::1-2001:db8::ff.
+// Names are lower cased without the trailing dot and addresses are in their
+// canonical form, so equal targets have the same String.
+func parseTarget(s string) (target, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return target{}, fmt.Errorf("%w: empty host", ErrInvalidHost)
+	}
+
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return target{}, fmt.Errorf("%w: %s", ErrInvalidHost, err)
+		}
+		if a := p.Addr(); a.Is4In6() && p.Bits() >= 96 {
+			p = netip.PrefixFrom(a.Unmap(), p.Bits()-96)
+		}
+		p = p.Masked()
+		if p.IsSingleIP() {
+			return target{first: p.Addr(), last: p.Addr()}, nil
+		}
+		t := target{prefix: p, first: p.Addr(), last: lastAddr(p)}
+		return t, t.check(s)
+	}
+
+	if addr, err := netip.ParseAddr(strings.Trim(s, "[]")); err == nil {
+		return target{first: addr.Unmap(), last: addr.Unmap()}, nil
+	}
+
+	if i := strings.LastIndex(s, "-"); i > 0 {
+		if first, err := netip.ParseAddr(s[:i]); err == nil {
+			last, err := rangeEnd(first.Unmap(), s[i+1:])
+			if err != nil {
+				return target{}, fmt.Errorf("%w: %s: %s", ErrInvalidHost, s, err)
+			}
+			t := target{first: first.Unmap(), last: last}
+			return t, t.check(s)
+		}
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(s), ".")
+	if len(name) > 253 {
+		return target{}, fmt.Errorf("%w: %s: name too long", ErrInvalidHost, s)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) > 63 || !hostnameLabel.MatchString(label) {
+			return target{}, fmt.Errorf("%w: %s", ErrInvalidHost, s)
+		}
+	}
+	return target{name: name}, nil
+}
+
+// rangeEnd parses the end of a range starting at first. For IPv4 it can be
+// the last byte only.
+func rangeEnd(first netip.Addr, end string) (netip.Addr, error) {
+	if last, err := netip.ParseAddr(end); err == nil {
+		return last.Unmap(), nil
+	}
+	if !first.Is4() {
+		return netip.Addr{}, errors.New("range end must be an address")
+	}
+	n, err := strconv.ParseUint(end, 10, 8)
+	if err != nil {
+		return netip.Addr{}, errors.New("range end must be an address or a number from 0 to 255")
+	}
+	b := first.As4()
+	b[3] = byte(n)
+	return netip.AddrFrom4(b), nil
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// check validates the bounds of the range t parsed from s.
+func (t target) check(s string) error {
+	if t.first.Is4() != t.last.Is4() {
+		return fmt.Errorf("%w: %s: range mixes IPv4 and IPv6", ErrInvalidHost, s)
+	}
+	if t.last.Less(t.first) {
+		return fmt.Errorf("%w: %s: range end is before its start", ErrInvalidHost, s)
+	}
+	if t.size() > MaxRangeSize {
+		return fmt.Errorf("%w: %s: more than %d addresses", ErrInvalidHost, s, MaxRangeSize)
+	}
+	return nil
+}
+
+// size returns the number of hosts of t, or MaxRangeSize+1 when there are
+// more.
+func (t target) size() int {
+	if t.name != "" {
+		return 1
+	}
+	first, last := t.first.As16(), t.last.As16()
+	if binary.BigEndian.Uint64(first[:8]) != binary.BigEndian.Uint64(last[:8]) {
+		return MaxRangeSize + 1
+	}
+	n := binary.BigEndian.Uint64(last[8:]) - binary.BigEndian.Uint64(first[8:])
+	if n >= MaxRangeSize {
+		return MaxRangeSize + 1
+	}
+	return int(n) + 1
+}
+
+func (t target) String() string {
+	switch {
+	case t.name != "":
+		return t.name
+	case t.prefix.IsValid():
+		return t.prefix.String()
+	case t.first == t.last:
+		return t.first.String()
+	}
+	return t.first.String() + "-" + t.last.String()
+}
+
+// contains reports whether every host of o is a host of t.
+func (t target) contains(o target) bool {
+	if t.name != "" || o.name != "" {
+		return t.name == o.name
+	}
+	return !o.first.Less(t.first) && !t.last.Less(o.last) && t.first.Is4() == o.first.Is4()
+}
+
+// hosts returns the hosts of t.
+func (t target) hosts() []string {
+	if t.name != "" {
+		return []string{t.name}
+	}
+	hosts := make([]string, 0, t.size())
+	for a := t.first; a.IsValid() && !t.last.Less(a); a = a.Next() {
+		hosts = append(hosts, a.String())
+	}
+	return hosts
+}
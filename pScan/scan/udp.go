@@ -0,0 +1,80 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// DefaultUDPTimeout is how long a UDP probe waits for a reply when Options
+// sets no timeout.
+const DefaultUDPTimeout = 1 * time.Second
+
+var (
+	// root NS query
+	dnsPayload = []byte{
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x02, 0x00, 0x01,
+	}
+	// client request, version 3
+	ntpPayload = append([]byte{0x1b}, make([]byte, 47)...)
+	// v1 get-request of sysDescr.0 with the public community
+	snmpPayload = []byte{
+		0x30, 0x26, 0x02, 0x01, 0x00, 0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x19, 0x02, 0x01, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00,
+		0x30, 0x0e, 0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x05, 0x00,
+	}
+	genericPayload = []byte("\r\n\r\n")
+)
+
+// udpPayload returns the probe sent to port. Services ignore datagrams they
+// cannot parse, so well-known ports get a valid request.
+func udpPayload(port int) []byte {
+	switch port {
+	case 53:
+		return dnsPayload
+	case 123:
+		return ntpPayload
+	case 161:
+		return snmpPayload
+	}
+	return genericPayload
+}
+
+// probeUDP sends a payload to addr. A reply means the port is open and an
+// ICMP port unreachable, reported as a refused connection, that it is closed.
+// Without either the port is open or filtered.
+func probeUDP(ctx context.Context, dial DialFunc, addr string, port int, timeout time.Duration) State {
+	conn, err := dial(ctx, "udp", addr)
+	if err != nil {
+		return Unreachable
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write(udpPayload(port)); err != nil {
+		return udpState(err)
+	}
+	_, err = conn.Read(make([]byte, 1500))
+	if err == nil {
+		return Open
+	}
+	return udpState(err)
+}
+
+// udpState returns the state of a port a UDP probe got an error from.
+func udpState(err error) State {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return Closed
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return OpenFiltered
+	}
+	return Unreachable
+}
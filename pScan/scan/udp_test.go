@@ -0,0 +1,117 @@
+package scan_test
+
+import (
+	"context"
+	"go-cmd-book/pScan/scan"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listenUDP opens a local UDP listener that passes every datagram it reads
+// to handle, and replies with what handle returns unless it is nil.
+func listenUDP(t *testing.T, handle func(b []byte) []byte) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if reply := handle(buf[:n]); reply != nil {
+				conn.WriteToUDP(reply, addr)
+			}
+		}
+	}()
+	return conn
+}
+
+func udpPort(conn *net.UDPConn) int {
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestUDP(t *testing.T) {
+	hl := &scan.HostList{"127.0.0.1"}
+	// open|filtered is not retried, the port stays silent
+	opts := scan.Options{UDPTimeout: 100 * time.Millisecond, Retries: 2}
+
+	echo := listenUDP(t, func(b []byte) []byte { return b })
+	probes := atomic.Int32{}
+	silent := listenUDP(t, func(b []byte) []byte {
+		probes.Add(1)
+		return nil
+	})
+	closedConn := listenUDP(t, func(b []byte) []byte { return nil })
+	closed := udpPort(closedConn)
+	closedConn.Close()
+
+	results, err := scan.RunContext(context.Background(), hl, []int{udpPort(echo), udpPort(silent), closed}, "udp", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []scan.PortState{
+		{Port: udpPort(echo), State: scan.Open},
+		{Port: udpPort(silent), State: scan.OpenFiltered},
+		{Port: closed},
+	}, results[0].PortStates)
+
+	states := []string{}
+	for _, p := range results[0].PortStates {
+		states = append(states, p.State.String())
+	}
+	assert.Equal(t, []string{"open", "open|filtered", "closed"}, states)
+	assert.Equal(t, int32(1), probes.Load())
+}
+
+func TestUDPPayloads(t *testing.T) {
+	received := make(chan []byte, 1)
+	ln := listenUDP(t, func(b []byte) []byte {
+		received <- append([]byte{}, b...)
+		return []byte{0}
+	})
+	// send the probes of the well-known ports to the listener
+	d := &net.Dialer{}
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return d.DialContext(ctx, network, ln.LocalAddr().String())
+	}
+
+	testCases := []struct {
+		name  string
+		port  int
+		check func(t *testing.T, b []byte)
+	}{
+		{"dns", 53, func(t *testing.T, b []byte) {
+			// one question, recursion desired
+			assert.GreaterOrEqual(t, len(b), 12)
+			assert.Equal(t, []byte{0x01, 0x00, 0x00, 0x01}, b[2:6])
+		}},
+		{"ntp", 123, func(t *testing.T, b []byte) {
+			// version 3 client request
+			assert.Len(t, b, 48)
+			assert.Equal(t, byte(0x1b), b[0])
+		}},
+		{"snmp", 161, func(t *testing.T, b []byte) {
+			// a DER sequence spanning the datagram with the public community
+			assert.Equal(t, byte(0x30), b[0])
+			assert.Equal(t, len(b)-2, int(b[1]))
+			assert.Contains(t, string(b), "public")
+		}},
+		{"generic", 9999, func(t *testing.T, b []byte) {
+			assert.NotEmpty(t, b)
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := scan.RunContext(context.Background(), &scan.HostList{"127.0.0.1"}, []int{tc.port}, "udp",
+				scan.Options{Dial: dial, UDPTimeout: time.Second})
+			assert.NoError(t, err)
+			assert.Equal(t, "open", results[0].PortStates[0].State.String())
+			tc.check(t, <-received)
+		})
+	}
+}